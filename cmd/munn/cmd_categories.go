@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	chart "github.com/wcharczuk/go-chart"
+)
+
+func init() {
+	setupCategoriesCmd()
+	rootCmd.AddCommand(categoriesCmd)
+}
+
+func setupCategoriesCmd() {
+	categoriesCmd.Flags().IntP("years", "y", 0, "Number of years to project (default 3 if not specified as a flag or in .munn file)")
+	categoriesCmd.Flags().StringP("format", "f", "text", "Output format: text or json")
+	categoriesCmd.Flags().StringP("chart", "c", "", "Generate a pie or bar chart image")
+}
+
+var categoryCharts = []string{"pie", "bar"}
+
+var categoriesCmd = &cobra.Command{
+	Use:          "categories FILE",
+	Short:        "Print how much is spent on each category of expenses",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flagYears, _ := cmd.Flags().GetInt("years")
+		format, _ := cmd.Flags().GetString("format")
+		chartType, _ := cmd.Flags().GetString("chart")
+		fileName := args[0]
+
+		// Check the chart first, so a bad one doesn't leave an empty image behind
+		if chartType != "" {
+			if err := checkOption("chart", chartType, categoryCharts); err != nil {
+				return err
+			}
+		}
+
+		p, warnings, err := parseFile(fileName)
+		if err != nil {
+			return err
+		}
+		printWarnings(cmd, warnings)
+		p.Project(projectionYears(p, flagYears))
+		report := p.CategoryReport()
+
+		switch format {
+		case "text":
+			cmd.Println(report)
+		case "json":
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid format: %s", format)
+		}
+
+		if chartType == "" {
+			return nil
+		}
+		if len(report.Categories) == 0 {
+			return fmt.Errorf("no expenses to chart")
+		}
+
+		name := strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".categories.png"
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		switch chartType {
+		case "pie":
+			err = report.PieChart().Render(chart.PNG, f)
+		case "bar":
+			err = report.BarChart().Render(chart.PNG, f)
+		}
+		if err != nil {
+			return err
+		}
+		cmd.PrintErrf("Wrote image to %s\n", name)
+		return nil
+	},
+}
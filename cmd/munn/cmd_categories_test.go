@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Shamus03/munn"
+	"github.com/stretchr/testify/suite"
+)
+
+type categoriesCmdSuite struct {
+	suite.Suite
+
+	output *strings.Builder
+}
+
+func Test_CategoriesCmd(t *testing.T) {
+	suite.Run(t, &categoriesCmdSuite{})
+}
+
+func (s *categoriesCmdSuite) SetupTest() {
+	categoriesCmd.ResetFlags()
+	setupCategoriesCmd()
+
+	buf := new(strings.Builder)
+	rootCmd.SetOutput(buf)
+	s.output = buf
+}
+
+func (s *categoriesCmdSuite) run(args ...string) string {
+	rootCmd.SetArgs(append([]string{"categories"}, args...))
+	s.Require().Nil(rootCmd.Execute())
+	return s.output.String()
+}
+
+func (s *categoriesCmdSuite) Test_Example() {
+	assert := s.Assert()
+	lines := strings.Split(strings.Trim(s.run("example.munn"), "\n"), "\n")
+
+	if assert.True(len(lines) > 2, "should have at least 2 lines") {
+		assert.Equal("Category\tSub-category\tMonthly\tYearly\tShare\t2019\t2020\t2021\t2022", lines[0])
+		assert.True(strings.HasPrefix(lines[1], "Debt\t\t"), lines[1])
+		assert.True(strings.HasSuffix(lines[1], "\t0.00\t12000.00\t12000.00\t12000.00"), lines[1])
+		assert.True(strings.HasPrefix(lines[len(lines)-1], "Total\t\t"), lines[len(lines)-1])
+		assert.Contains(strings.Join(lines, "\n"), "\t2.31%\t0.00\t600.00\t600.00\t600.00")
+	}
+}
+
+func (s *categoriesCmdSuite) Test_InvalidChart() {
+	assert := s.Assert()
+	example, err := ioutil.ReadFile("example.munn")
+	s.Require().Nil(err)
+	file := tempFile(s.T(), "example.munn", string(example))
+
+	rootCmd.SetArgs([]string{"categories", file, "--chart", "bogus"})
+	assert.EqualError(rootCmd.Execute(), "invalid chart: bogus (must be one of pie, bar)")
+	assert.NotContains(s.output.String(), "Category")
+
+	_, err = os.Stat(filepath.Join(filepath.Dir(file), "example.categories.png"))
+	assert.True(os.IsNotExist(err), "the image shouldn't be created for an invalid chart")
+}
+
+func (s *categoriesCmdSuite) Test_Example_JSON() {
+	assert := s.Assert()
+	var report munn.CategoryReport
+	assert.Nil(json.Unmarshal([]byte(s.run("example.munn", "--format", "json")), &report))
+
+	if assert.NotEmpty(report.Categories) {
+		assert.Equal("Debt", report.Categories[0].Category)
+		assert.Equal(float32(1), report.Total.Share)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Shamus03/munn"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	setupFmtCmd()
+	rootCmd.AddCommand(fmtCmd)
+}
+
+func setupFmtCmd() {
+	fmtCmd.Flags().BoolP("check", "l", false, "List files which aren't formatted instead of rewriting them, and fail if there are any")
+}
+
+var fmtCmd = &cobra.Command{
+	Use:          "fmt FILE...",
+	Short:        "Rewrite .munn files in a canonical layout, keeping comments and anchors",
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		check, _ := cmd.Flags().GetBool("check")
+
+		var unformatted int
+		for _, fileName := range args {
+			if ext := strings.ToLower(filepath.Ext(fileName)); ext == ".json" || ext == ".toml" {
+				return fmt.Errorf("%s: only YAML files can be formatted", fileName)
+			}
+			src, err := ioutil.ReadFile(fileName)
+			if err != nil {
+				return err
+			}
+			formatted, err := munn.Format(src)
+			if err != nil {
+				return fmt.Errorf("%s: %v", fileName, err)
+			}
+			if bytes.Equal(src, formatted) {
+				continue
+			}
+
+			if check {
+				cmd.Println(fileName)
+				unformatted++
+				continue
+			}
+			info, err := os.Stat(fileName)
+			if err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(fileName, formatted, info.Mode()); err != nil {
+				return err
+			}
+		}
+
+		if unformatted > 0 {
+			return fmt.Errorf("found %d unformatted files", unformatted)
+		}
+		return nil
+	},
+}
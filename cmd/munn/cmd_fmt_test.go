@@ -0,0 +1,198 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/Shamus03/munn"
+	"github.com/stretchr/testify/suite"
+)
+
+type fmtCmdSuite struct {
+	suite.Suite
+
+	output *strings.Builder
+}
+
+func Test_FmtCmd(t *testing.T) {
+	suite.Run(t, &fmtCmdSuite{})
+}
+
+func (s *fmtCmdSuite) SetupTest() {
+	fmtCmd.ResetFlags()
+	setupFmtCmd()
+
+	buf := new(strings.Builder)
+	rootCmd.SetOutput(buf)
+	s.output = buf
+}
+
+func (s *fmtCmdSuite) run(args ...string) error {
+	rootCmd.SetArgs(append([]string{"fmt"}, args...))
+	return rootCmd.Execute()
+}
+
+func (s *fmtCmdSuite) file(contents string) string {
+	return tempFile(s.T(), "fmt.munn", contents)
+}
+
+func (s *fmtCmdSuite) read(name string) string {
+	b, err := ioutil.ReadFile(name)
+	s.Require().Nil(err)
+	return string(b)
+}
+
+const fmtMessy = `# My portfolio
+
+accounts:
+- name: Savings
+  id: &savings 2
+- id: &bank 1
+  name: Bank
+transactions:
+- schedule: monthly
+  amount: 700
+  description: Rent # due on the 1st
+  fromAccount: *bank
+- description: Paycheck
+  toAccount: *savings
+  schedule: Weekly(thursday)
+  amount: 600
+  start: 2020-02-01T00:00:00Z
+manualAdjustments:
+- account: *savings
+  time: 2020-02-01
+  balance: 500
+- balance: 1000
+  time: '2020-01-01'
+  account: *bank
+yearsToProject: 5
+`
+
+const fmtFormatted = `# My portfolio
+
+yearsToProject: 5
+accounts:
+- id: &bank 1
+  name: Bank
+- id: &savings 2
+  name: Savings
+manualAdjustments:
+- account: *bank
+  time: '2020-01-01'
+  balance: 1000
+- account: *savings
+  time: '2020-02-01'
+  balance: 500
+transactions:
+- fromAccount: *bank
+  description: Rent # due on the 1st
+  amount: 700
+  schedule: Monthly(1)
+- toAccount: *savings
+  description: Paycheck
+  amount: 600
+  schedule: Weekly(Thursday)
+  start: '2020-02-01'
+`
+
+func (s *fmtCmdSuite) Test_Fmt() {
+	assert := s.Assert()
+	file := s.file(fmtMessy)
+
+	s.Require().Nil(s.run(file))
+	assert.Equal(fmtFormatted, s.read(file))
+	assert.Empty(s.output.String())
+
+	// Formatting again doesn't change anything
+	s.Require().Nil(s.run(file))
+	assert.Equal(fmtFormatted, s.read(file))
+
+	_, err := munn.ParseFile(file)
+	assert.Nil(err)
+
+	// Only fmt fixes the case of schedule names
+	_, err = munn.ParseFile(s.file(fmtMessy))
+	assert.Contains(err.Error(), "no schedule parser registered for name: monthly")
+}
+
+func (s *fmtCmdSuite) Test_Fmt_Nested() {
+	assert := s.Assert()
+	formatted := `accounts:
+- name: Bank
+  tags:
+  - Cash
+  - Liquid
+transactions:
+- fromAccount:
+  - Bank
+  - Savings
+  description: Rent
+  amount: 700
+  schedule: Monthly(1)
+  notes: |
+    due on the 1st
+    - or the 2nd
+# the end
+`
+	file := s.file(formatted)
+
+	s.Require().Nil(s.run(file))
+	assert.Equal(formatted, s.read(file))
+}
+
+func (s *fmtCmdSuite) Test_Fmt_MultipleDocuments() {
+	file := s.file("accounts:\n- name: Bank\n---\naccounts:\n- name: Savings\n")
+
+	s.Assert().EqualError(s.run(file), file+": only files with one YAML document can be formatted")
+}
+
+func (s *fmtCmdSuite) Test_Fmt_Example() {
+	require := s.Require()
+	example, err := ioutil.ReadFile("example.munn")
+	require.Nil(err)
+	file := s.file(string(example))
+	require.Nil(s.run(file))
+
+	// The formatted file is the same portfolio
+	before, err := munn.ParseFile("example.munn")
+	require.Nil(err)
+	after, err := munn.ParseFile(file)
+	require.Nil(err)
+	expected, err := munn.Marshal(before)
+	require.Nil(err)
+	actual, err := munn.Marshal(after)
+	require.Nil(err)
+	s.Assert().Equal(string(expected), string(actual))
+}
+
+func (s *fmtCmdSuite) Test_Fmt_Check() {
+	assert := s.Assert()
+	messy := s.file(fmtMessy)
+	formatted := s.file(fmtFormatted)
+
+	err := s.run("--check", messy, formatted)
+	assert.EqualError(err, "found 1 unformatted files")
+	assert.Equal(messy+"\nError: found 1 unformatted files\n", s.output.String())
+	assert.Equal(fmtMessy, s.read(messy))
+
+	s.output.Reset()
+	assert.Nil(s.run("-l", formatted))
+	assert.Empty(s.output.String())
+}
+
+func (s *fmtCmdSuite) Test_Fmt_SharedAnchors() {
+	assert := s.Assert()
+	// Sorting would put the alias before its anchor, so the accounts are left in order
+	file := s.file(`accounts:
+  - id: 2
+    name: Savings
+    rmdAccount: &bank 1
+  - id: *bank
+    name: Bank
+`)
+
+	s.Require().Nil(s.run(file))
+	assert.Contains(s.read(file), "- id: 2\n")
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Shamus03/munn"
+	"github.com/spf13/cobra"
+)
+
+var goalSeekRetirementPlan retirementPlanFlag
+
+func init() {
+	setupGoalSeekCmd()
+	rootCmd.AddCommand(goalSeekCmd)
+}
+
+func setupGoalSeekCmd() {
+	goalSeekCmd.Flags().IntP("years", "y", 0, "Number of years to project (default 3 if not specified as a flag or in .munn file)")
+	goalSeekRetirementPlan.RetirementPlan = nil
+	goalSeekCmd.Flags().VarP(&goalSeekRetirementPlan, "retire", "r", "Use a retirement plan")
+
+	goalSeekCmd.Flags().String("balance", "", "Goal: reach a total balance by a date, eg. '2050-01-01:1000000'")
+	goalSeekCmd.Flags().String("retire-by", "", "Goal: find a retirement date on or before a date (requires --retire)")
+	goalSeekCmd.Flags().Bool("never-deplete", false, "Goal: never let the total balance drop to zero")
+
+	goalSeekCmd.Flags().String("amount", "", "Solve for the amount of the transaction with this description")
+	goalSeekCmd.Flags().Bool("expenses", false, "Solve for the yearly expenses of the retirement plan (requires --retire)")
+	goalSeekCmd.Flags().String("start", "", "Solve for the start date of the transaction with this description")
+
+	goalSeekCmd.Flags().String("min", "", "Lowest value to search (a date when solving for --start)")
+	goalSeekCmd.Flags().String("max", "", "Highest value to search (a date when solving for --start)")
+	goalSeekCmd.Flags().Float64("tolerance", 0.01, "Stop searching once the range is this small")
+}
+
+var goalSeekCmd = &cobra.Command{
+	Use:          "goalseek FILE",
+	Aliases:      []string{"goal-seek"},
+	Short:        "Solve for a transaction amount, retirement expenses or start date that reaches a goal",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flagYears, _ := cmd.Flags().GetInt("years")
+		balance, _ := cmd.Flags().GetString("balance")
+		retireBy, _ := cmd.Flags().GetString("retire-by")
+		neverDeplete, _ := cmd.Flags().GetBool("never-deplete")
+		amount, _ := cmd.Flags().GetString("amount")
+		expenses, _ := cmd.Flags().GetBool("expenses")
+		start, _ := cmd.Flags().GetString("start")
+		minFlag, _ := cmd.Flags().GetString("min")
+		maxFlag, _ := cmd.Flags().GetString("max")
+		tolerance, _ := cmd.Flags().GetFloat64("tolerance")
+		fileName := args[0]
+
+		var goals []munn.Goal
+		if balance != "" {
+			spl := strings.Split(balance, ":")
+			if len(spl) != 2 {
+				return fmt.Errorf("expected 'date:balance' eg. '2006-01-02:123'")
+			}
+			date, err := time.Parse("2006-01-02", spl[0])
+			if err != nil {
+				return err
+			}
+			b, err := strconv.ParseFloat(spl[1], 32)
+			if err != nil {
+				return err
+			}
+			goals = append(goals, munn.BalanceGoal(date, float32(b)))
+		}
+		if retireBy != "" {
+			date, err := time.Parse("2006-01-02", retireBy)
+			if err != nil {
+				return err
+			}
+			goals = append(goals, munn.RetireByGoal(date))
+		}
+		if neverDeplete {
+			goals = append(goals, munn.NeverDepleteGoal())
+		}
+		if len(goals) != 1 {
+			return fmt.Errorf("exactly one of --balance, --retire-by or --never-deplete is required")
+		}
+
+		if minFlag == "" || maxFlag == "" {
+			return fmt.Errorf("--min and --max are required")
+		}
+
+		var variables []munn.GoalVariable
+		var min, max float64
+		if amount != "" {
+			variables = append(variables, munn.TransactionAmount(amount))
+		}
+		if expenses {
+			variables = append(variables, munn.RetirementExpenses())
+		}
+		if start != "" {
+			minDate, err := time.Parse("2006-01-02", minFlag)
+			if err != nil {
+				return err
+			}
+			maxDate, err := time.Parse("2006-01-02", maxFlag)
+			if err != nil {
+				return err
+			}
+			variables = append(variables, munn.TransactionStart(start, minDate))
+			max = maxDate.Sub(minDate).Hours() / 24
+		} else {
+			var err error
+			if min, err = strconv.ParseFloat(minFlag, 64); err != nil {
+				return err
+			}
+			if max, err = strconv.ParseFloat(maxFlag, 64); err != nil {
+				return err
+			}
+		}
+		if len(variables) != 1 {
+			return fmt.Errorf("exactly one of --amount, --expenses or --start is required")
+		}
+
+		if (retireBy != "" || expenses) && goalSeekRetirementPlan.RetirementPlan == nil {
+			return fmt.Errorf("--retire is required")
+		}
+
+		var years int
+		build := func() (*munn.Portfolio, error) {
+			p, _, err := parseFile(fileName)
+			if err != nil {
+				return nil, err
+			}
+			years = projectionYears(p, flagYears)
+
+			// Each projection needs its own copy of the plan, since the retirement date is only found once
+			if goalSeekRetirementPlan.RetirementPlan != nil {
+				rp := *goalSeekRetirementPlan.RetirementPlan
+				p.RetirementPlan = &rp
+			}
+			return p, nil
+		}
+		_, warnings, err := parseFile(fileName)
+		if err != nil {
+			return err
+		}
+		printWarnings(cmd, warnings)
+		if _, err := build(); err != nil {
+			return err
+		}
+
+		v, err := munn.GoalSeek(build, years, goals[0], variables[0], min, max, tolerance)
+		if err != nil {
+			return err
+		}
+		cmd.Printf("Solution: %s\n", variables[0].Format(v))
+		return nil
+	},
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Shamus03/munn"
+	"github.com/stretchr/testify/suite"
+)
+
+type goalSeekCmdSuite struct {
+	suite.Suite
+
+	output *strings.Builder
+}
+
+func Test_GoalSeekCmd(t *testing.T) {
+	suite.Run(t, &goalSeekCmdSuite{})
+}
+
+func (s *goalSeekCmdSuite) SetupTest() {
+	goalSeekCmd.ResetFlags()
+	setupGoalSeekCmd()
+
+	buf := new(strings.Builder)
+	rootCmd.SetOutput(buf)
+	s.output = buf
+}
+
+func (s *goalSeekCmdSuite) run(args ...string) []string {
+	rootCmd.SetArgs(append([]string{"goalseek"}, args...))
+	s.Require().Nil(rootCmd.Execute())
+	return strings.Split(strings.Trim(s.output.String(), "\n"), "\n")
+}
+
+func (s *goalSeekCmdSuite) Test_Example_Amount() {
+	assert := s.Assert()
+	lines := s.run("example.munn", "--balance", "2021-01-01:30000", "--amount", "Paycheck", "--min", "0", "--max", "2000")
+
+	if assert.Len(lines, 1) {
+		spl := strings.Split(lines[0], ": ")
+		assert.Equal("Solution", spl[0])
+		amt, err := strconv.ParseFloat(spl[1], 64)
+		assert.Nil(err)
+		assert.True(amt > 0 && amt < 2000, lines[0])
+	}
+}
+
+const goalSeekPortfolio = `
+yearsToProject: 3
+accounts:
+- name: Bank
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 1000
+transactions:
+- description: Paycheck
+  toAccount: Bank
+  amount: 300
+  schedule: Monthly(1)
+- description: Rent
+  fromAccount: Bank
+  amount: 200
+  schedule: Monthly(1)
+`
+
+func (s *goalSeekCmdSuite) Test_Goals() {
+	file := tempFile(s.T(), "goalseek.munn", goalSeekPortfolio)
+	for _, c := range []struct {
+		name     string
+		args     []string
+		solution string
+	}{
+		// Each goal and each variable, with a 1000 balance, 200 rent and a 300 paycheck by default
+		{"balance by amount", []string{"--balance", "2021-01-01:5000", "--amount", "Paycheck", "--min", "0", "--max", "2000"}, "533.34"},
+		{"never deplete by amount", []string{"--never-deplete", "--amount", "Paycheck", "--min", "0", "--max", "2000"}, "172.23"},
+		{"balance by start", []string{"--balance", "2022-01-01:3000", "--start", "Paycheck", "--min", "2020-01-01", "--max", "2022-01-01"}, "2020-03-30"},
+		{"retire by expenses", []string{"--retire-by", "2021-01-01", "--expenses", "--retire", "2060-01-01:1000", "--min", "0", "--max", "100000"}, "56.41"},
+		{"retire by amount", []string{"--retire-by", "2022-01-01", "--amount", "Paycheck", "--retire", "2060-01-01:1000", "--min", "0", "--max", "100000"}, "1741.67"},
+	} {
+		s.SetupTest()
+		lines := s.run(append([]string{file}, c.args...)...)
+		s.Assert().Equal([]string{"Solution: " + c.solution}, lines, c.name)
+	}
+}
+
+func (s *goalSeekCmdSuite) Test_Example_Unreachable() {
+	rootCmd.SetArgs([]string{"goalseek", "example.munn", "--balance", "2021-01-01:10000000", "--amount", "Paycheck", "--min", "0", "--max", "10"})
+	s.Assert().Equal(munn.ErrGoalUnreachable, rootCmd.Execute())
+}
+
+func (s *goalSeekCmdSuite) Test_Example_AlwaysMet() {
+	rootCmd.SetArgs([]string{"goalseek", "example.munn", "--balance", "2021-01-01:1", "--amount", "Paycheck", "--min", "0", "--max", "10"})
+	s.Assert().Equal(munn.ErrGoalAlwaysMet, rootCmd.Execute())
+}
+
+func (s *goalSeekCmdSuite) Test_Example_Tolerance() {
+	assert := s.Assert()
+	rootCmd.SetArgs([]string{"goalseek", "example.munn", "--balance", "2021-01-01:30000", "--amount", "Paycheck", "--min", "0", "--max", "2000", "--tolerance", "0"})
+	err := rootCmd.Execute()
+	s.Require().NotNil(err)
+	assert.Equal("tolerance must be more than 0: 0", err.Error())
+
+	// A tolerance too small to reach still stops, with the closest value found
+	s.output.Reset()
+	lines := s.run("example.munn", "--balance", "2021-01-01:30000", "--amount", "Paycheck", "--min", "0", "--max", "2000", "--tolerance", "1e-300")
+	assert.True(strings.HasPrefix(lines[0], "Solution: 696."), lines[0])
+}
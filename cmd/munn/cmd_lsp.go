@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(lspCmd)
+}
+
+var lspCmd = &cobra.Command{
+	Use:          "lsp",
+	Short:        "Run a language server for .munn files, speaking LSP over stdio",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return newLSPServer(cmd.InOrStdin(), cmd.OutOrStdout()).run()
+	},
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type lspCmdSuite struct {
+	suite.Suite
+
+	input  *strings.Builder
+	output *strings.Builder
+	nextID int
+}
+
+func Test_LSPCmd(t *testing.T) {
+	suite.Run(t, &lspCmdSuite{})
+}
+
+func (s *lspCmdSuite) SetupTest() {
+	s.input = new(strings.Builder)
+	s.output = new(strings.Builder)
+	s.nextID = 0
+	rootCmd.SetOutput(s.output)
+}
+
+// lspReply is a response or notification from the server.
+type lspReply struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *lspError       `json:"error"`
+}
+
+// send queues a message for the server, and returns its id if it is a request.
+func (s *lspCmdSuite) send(method string, request bool, params interface{}) int {
+	msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+	var id int
+	if request {
+		s.nextID++
+		id = s.nextID
+		msg["id"] = id
+	}
+	data, err := json.Marshal(msg)
+	s.Require().Nil(err)
+	fmt.Fprintf(s.input, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return id
+}
+
+// run runs the server on the queued messages, and returns what it sent back.
+func (s *lspCmdSuite) run() []lspReply {
+	s.send("shutdown", true, nil)
+	s.send("exit", false, nil)
+	rootCmd.SetIn(strings.NewReader(s.input.String()))
+	rootCmd.SetArgs([]string{"lsp"})
+	s.Require().Nil(rootCmd.Execute())
+
+	var replies []lspReply
+	r := bufio.NewReader(strings.NewReader(s.output.String()))
+	for {
+		header, err := r.ReadString('\n')
+		if err == io.EOF {
+			return replies
+		}
+		s.Require().Nil(err)
+		length, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "Content-Length:")))
+		s.Require().Nil(err)
+		_, err = r.ReadString('\n')
+		s.Require().Nil(err)
+
+		body := make([]byte, length)
+		_, err = io.ReadFull(r, body)
+		s.Require().Nil(err)
+		var reply lspReply
+		s.Require().Nil(json.Unmarshal(body, &reply))
+		replies = append(replies, reply)
+	}
+}
+
+func (s *lspCmdSuite) result(replies []lspReply, id int, v interface{}) {
+	for _, r := range replies {
+		if r.ID != nil && *r.ID == id {
+			s.Require().Nil(r.Error)
+			s.Require().Nil(json.Unmarshal(r.Result, v))
+			return
+		}
+	}
+	s.Require().Fail("no response", "id %d", id)
+}
+
+type lspPublished struct {
+	URI         string
+	Diagnostics []lspDiagnostic
+}
+
+func (s *lspCmdSuite) diagnostics(replies []lspReply) []lspPublished {
+	var published []lspPublished
+	for _, r := range replies {
+		if r.Method == "textDocument/publishDiagnostics" {
+			var p lspPublished
+			s.Require().Nil(json.Unmarshal(r.Params, &p))
+			published = append(published, p)
+		}
+	}
+	return published
+}
+
+func (s *lspCmdSuite) files(files map[string]string) string {
+	return tempFiles(s.T(), files)
+}
+
+func (s *lspCmdSuite) at(uri string, line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+const lspPortfolio = `accounts:
+- name: Bank
+- name: Savings
+  group: Cash
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 1000
+- account: Savings
+  time: '2020-01-01'
+  balance: 0
+transactions:
+- description: Paycheck
+  toAccount: Bank
+  schedule: Monthly(15)
+  amount: 1000
+- description: Save
+  fromAccount:
+  - Bank
+  toAccount: Savings
+  schedule: Monthly(1)
+  amount: 100
+`
+
+func (s *lspCmdSuite) Test_LSP() {
+	assert := s.Assert()
+	uri := pathURI(filepath.Join(s.files(nil), "portfolio.munn"))
+	broken := strings.Replace(lspPortfolio, "toAccount: Savings", "toAccount: Sa", 1)
+
+	initialize := s.send("initialize", true, map[string]interface{}{"capabilities": map[string]interface{}{}})
+	s.send("initialized", false, map[string]interface{}{})
+	s.send("textDocument/didOpen", false, map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "yaml", "version": 1, "text": lspPortfolio},
+	})
+	hover := s.send("textDocument/hover", true, s.at(uri, 14, 4))
+	definition := s.send("textDocument/definition", true, s.at(uri, 13, 14))
+	noDefinition := s.send("textDocument/definition", true, s.at(uri, 12, 4))
+	s.send("textDocument/didChange", false, map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []interface{}{map[string]interface{}{"text": broken}},
+	})
+	accounts := s.send("textDocument/completion", true, s.at(uri, 19, 15))
+	listAccounts := s.send("textDocument/completion", true, s.at(uri, 18, 4))
+	schedules := s.send("textDocument/completion", true, s.at(uri, 20, 12))
+	unknown := s.send("textDocument/formatting", true, s.at(uri, 0, 0))
+	replies := s.run()
+
+	var init struct {
+		Capabilities struct {
+			HoverProvider      bool
+			DefinitionProvider bool
+		}
+	}
+	s.result(replies, initialize, &init)
+	assert.True(init.Capabilities.HoverProvider)
+	assert.True(init.Capabilities.DefinitionProvider)
+
+	var h lspHover
+	s.result(replies, hover, &h)
+	assert.Equal("**Paycheck**\n\n1000.00 Monthly(15), about 12000.00 a year\n\nNext:\n"+
+		"- 2020-02-15: 1000.00\n- 2020-03-15: 1000.00\n- 2020-04-15: 1000.00\n- 2020-05-15: 1000.00\n- 2020-06-15: 1000.00", h.Contents.Value)
+
+	var loc *lspLocation
+	s.result(replies, definition, &loc)
+	s.Require().NotNil(loc)
+	assert.Equal(uri, loc.URI)
+	assert.Equal(lspRange{Start: lspPosition{Line: 1, Character: 8}, End: lspPosition{Line: 1, Character: 12}}, loc.Range)
+	loc = nil
+	s.result(replies, noDefinition, &loc)
+	assert.Nil(loc)
+
+	published := s.diagnostics(replies)
+	s.Require().Len(published, 2)
+	assert.Empty(published[0].Diagnostics)
+	s.Require().Len(published[1].Diagnostics, 1)
+	d := published[1].Diagnostics[0]
+	assert.Equal(1, d.Severity)
+	assert.Contains(d.Message, "unknown account 'Sa'")
+	assert.Equal(lspRange{Start: lspPosition{Line: 19, Character: 13}, End: lspPosition{Line: 19, Character: 15}}, d.Range)
+
+	// The broken file can't be parsed, so accounts are completed from the last version which could
+	var items []lspCompletionItem
+	s.result(replies, accounts, &items)
+	s.Require().Len(items, 2)
+	assert.Equal("Bank", items[0].Label)
+	assert.Equal("Savings", items[1].Label)
+	assert.Equal("Cash", items[1].Detail)
+	assert.Equal(lspRange{Start: lspPosition{Line: 19, Character: 13}, End: lspPosition{Line: 19, Character: 15}}, items[1].TextEdit.Range)
+
+	s.result(replies, listAccounts, &items)
+	assert.Len(items, 2)
+
+	s.result(replies, schedules, &items)
+	var names []string
+	for _, item := range items {
+		names = append(names, item.Label)
+	}
+	assert.Equal([]string{"Biweekly", "Monthly", "Once", "Weekly", "Yearly"}, names)
+	assert.Equal(lspRange{Start: lspPosition{Line: 20, Character: 12}, End: lspPosition{Line: 20, Character: 12}}, items[0].TextEdit.Range)
+
+	for _, r := range replies {
+		if r.ID != nil && *r.ID == unknown {
+			s.Require().NotNil(r.Error)
+			assert.Equal(lspMethodNotFound, r.Error.Code)
+		}
+	}
+}
+
+func (s *lspCmdSuite) Test_LSP_Include() {
+	assert := s.Assert()
+	dir := s.files(map[string]string{
+		"accounts.munn": "accounts:\n- name: Bank\n  anualInterestRate: 0.01\n",
+	})
+	main := `include: [accounts.munn]
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 1000
+`
+	uri := pathURI(filepath.Join(dir, "main.munn"))
+
+	s.send("textDocument/didOpen", false, map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "yaml", "version": 1, "text": main},
+	})
+	s.send("textDocument/didChange", false, map[string]interface{}{
+		"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []interface{}{map[string]interface{}{"text": main}},
+	})
+	replies := s.run()
+
+	// Diagnostics in included files are shown at the top of the file including them
+	published := s.diagnostics(replies)
+	s.Require().Len(published, 2)
+	s.Require().Len(published[0].Diagnostics, 1)
+	d := published[0].Diagnostics[0]
+	assert.Equal(lspRange{}, d.Range)
+	assert.Equal(filepath.Join(dir, "accounts.munn")+":3:3: error: unknown field 'anualInterestRate' (did you mean 'annualInterestRate'?)", d.Message)
+}
+
+func (s *lspCmdSuite) Test_LSP_IncludeDefinition() {
+	assert := s.Assert()
+	dir := s.files(map[string]string{
+		"accounts.munn": "accounts:\n- name: Bank\n",
+	})
+	main := `include: [accounts.munn]
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 1000
+`
+	uri := pathURI(filepath.Join(dir, "main.munn"))
+
+	s.send("textDocument/didOpen", false, map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "yaml", "version": 1, "text": main},
+	})
+	definition := s.send("textDocument/definition", true, s.at(uri, 2, 11))
+	replies := s.run()
+
+	var loc *lspLocation
+	s.result(replies, definition, &loc)
+	s.Require().NotNil(loc)
+	assert.Equal(pathURI(filepath.Join(dir, "accounts.munn")), loc.URI)
+	assert.Equal(lspRange{Start: lspPosition{Line: 1, Character: 8}, End: lspPosition{Line: 1, Character: 12}}, loc.Range)
+}
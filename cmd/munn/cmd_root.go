@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -23,8 +24,17 @@ func setupRootCmd() {
 	rootCmd.Flags().IntP("years", "y", 0, "Number of years to project (default 3 if not specified as a flag or in .munn file)")
 	rootCmd.Flags().BoolP("image", "i", false, "Generate an image")
 	rootCmd.Flags().BoolP("stats", "s", false, "Print stats for the portfolio")
+	rootCmd.Flags().BoolP("taxes", "t", false, "Print yearly income taxes for the portfolio")
+	rootCmd.Flags().BoolP("gains", "g", false, "Print yearly realized capital gains for the portfolio")
 	rootCmd.Flags().BoolP("debug", "d", false, "Debug account changes")
 	rootCmd.Flags().BoolP("watch", "w", false, "Watch input file")
+	rootCmd.Flags().String("group-by", "account", "Aggregate balances by account, group or tag")
+	rootCmd.Flags().StringP("format", "f", "text", "Output format: text, csv, tsv, json, ndjson or xlsx")
+	rootCmd.Flags().StringSlice("columns", outputColumns, "Columns to print: time, account, balance and events (JSON has every column)")
+	rootCmd.Flags().StringP("output", "o", "", "Write the projection to a file instead of stdout")
+	rootCmd.Flags().String("interval", "", "Resample the projection to a record for each day, week, month, quarter or year")
+	rootCmd.Flags().String("sample", "end", "How to sample balances with --interval: end, min, max or average")
+	rootCmd.Flags().Bool("wide", false, "Print a row for each date with a column for each account, then the groups from --group-by and the total")
 	retirementPlan.RetirementPlan = nil
 	rootCmd.Flags().VarP(&retirementPlan, "retire", "r", "Use a retirement plan")
 	rootCmd.SetOut(os.Stdout)
@@ -37,39 +47,115 @@ var rootCmd = &cobra.Command{
 		flagYears, _ := cmd.Flags().GetInt("years")
 		image, _ := cmd.Flags().GetBool("image")
 		stats, _ := cmd.Flags().GetBool("stats")
+		taxes, _ := cmd.Flags().GetBool("taxes")
+		gains, _ := cmd.Flags().GetBool("gains")
 		debug, _ := cmd.Flags().GetBool("debug")
 		watch, _ := cmd.Flags().GetBool("watch")
+		groupBy, _ := cmd.Flags().GetString("group-by")
+		format, _ := cmd.Flags().GetString("format")
+		columns, _ := cmd.Flags().GetStringSlice("columns")
+		output, _ := cmd.Flags().GetString("output")
+		wide, _ := cmd.Flags().GetBool("wide")
+		intervalName, _ := cmd.Flags().GetString("interval")
+		samplingName, _ := cmd.Flags().GetString("sample")
 		fileName := args[0]
 
+		// Included files are watched along with the main file
+		var files []string
 		run := func() error {
-			f, err := os.Open(fileName)
-			if err != nil {
+			if err := checkOption("format", format, outputFormats); err != nil {
 				return err
 			}
+			if wide {
+				if err := checkOption("format for --wide", format, wideFormats); err != nil {
+					return err
+				}
+			}
+			for _, c := range columns {
+				if err := checkOption("column", c, outputColumns); err != nil {
+					return err
+				}
+			}
 
-			p, err := munn.Parse(f)
+			p, warnings, err := parseFile(fileName)
 			if err != nil {
 				return err
 			}
+			files = p.Files()
+			printWarnings(cmd, warnings)
 			p.Debug = debug
 
-			var years int
-			if flagYears != 0 {
-				years = flagYears
-			} else if p.YearsToProject != nil {
-				years = *p.YearsToProject
-			} else {
-				years = 3
-			}
+			years := projectionYears(p, flagYears)
 
 			if retirementPlan.RetirementPlan != nil {
 				p.RetirementPlan = retirementPlan.RetirementPlan
 			}
 
-			recs := p.Project(years)
+			grouping, err := munn.ParseGrouping(groupBy)
+			if err != nil {
+				return err
+			}
+
+			sampling, err := munn.ParseSampling(samplingName)
+			if err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("sample") && intervalName == "" {
+				return fmt.Errorf("--sample requires --interval")
+			}
+			var interval munn.Interval
+			if intervalName != "" {
+				if interval, err = munn.ParseInterval(intervalName); err != nil {
+					return err
+				}
+			}
+
+			projected := p.Project(years)
+			recs := p.GroupRecords(projected, grouping)
+			if intervalName != "" {
+				// Groups are resampled after grouping, so their lowest and highest balances are of the whole group
+				projected = munn.ResampleRecords(projected, interval, sampling)
+				recs = munn.ResampleRecords(recs, interval, sampling)
+			}
+
+			// Summaries go to stderr when they would break a machine readable projection on stdout
+			out, summary := cmd.OutOrStdout(), cmd.OutOrStdout()
+			outputName := output
+			if outputName == "" && format == "xlsx" {
+				// Workbooks aren't printed, so they are written next to the input file like images
+				outputName = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + ".xlsx"
+			}
+			// Images are written next to the input file, so the output file is only for the projection
+			if outputName != "" && !image {
+				f, err := os.Create(outputName)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				out = f
+			} else if format != "text" {
+				summary = cmd.ErrOrStderr()
+			}
 
 			if stats {
-				cmd.Println(p.Stats())
+				fmt.Fprintln(summary, p.Stats())
+			}
+
+			if taxes {
+				if p.Taxes == nil {
+					return fmt.Errorf("no taxes in %s", fileName)
+				}
+				fmt.Fprintln(summary, "Year\tIncome\tDeductions\tTaxable income\tTax\tEffective rate\tMarginal rate\tWithheld")
+				for _, r := range p.Taxes.Records() {
+					fmt.Fprintf(summary, "%d\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f%%\t%.2f%%\t%.2f\n", r.Year, r.Income, r.Deductions, r.TaxableIncome, r.Tax, r.EffectiveRate*100, r.MarginalRate*100, r.Withheld)
+				}
+			}
+
+			if gains {
+				fmt.Fprintln(summary, "Year\tShort-term gains\tLong-term gains")
+				for _, r := range p.CapitalGains() {
+					fmt.Fprintf(summary, "%d\t%.2f\t%.2f\n", r.Year, r.ShortTerm, r.LongTerm)
+				}
 			}
 
 			if image {
@@ -80,24 +166,42 @@ var rootCmd = &cobra.Command{
 				}
 				defer f.Close()
 
-				if err := p.Chart(recs).Render(chart.PNG, f); err != nil {
+				// Tags can share accounts, so they aren't stacked on each other
+				graph := p.Chart(recs)
+				if groupBy == "tag" {
+					graph = p.LineChart(recs)
+				}
+				if err := graph.Render(chart.PNG, f); err != nil {
 					return err
 				}
-				cmd.Printf("Wrote image to %s\n", name)
-			} else {
-				for _, r := range recs {
-					cmd.Printf("%s\t%s\t%.2f\n", r.Time.Format("2006-01-02"), r.AccountName, r.Balance)
+				fmt.Fprintf(summary, "Wrote image to %s\n", name)
+			} else if wide || format == "xlsx" {
+				// Accounts always have their own columns, with columns for their groups too if they are grouped
+				var groups *munn.ProjectionTable
+				if groupBy != "" && groupBy != "account" {
+					t := munn.PivotRecords(recs)
+					groups = &t
 				}
+				if format == "xlsx" {
+					if err := writeWorkbook(out, p, munn.PivotRecords(projected), groups); err != nil {
+						return err
+					}
+					fmt.Fprintf(summary, "Wrote workbook to %s\n", outputName)
+				} else if err := writeWide(out, format, munn.PivotRecords(projected), groups); err != nil {
+					return err
+				}
+			} else if err := writeProjection(out, format, columns, p, recs, warnings); err != nil {
+				return err
 			}
 
-			cmd.Printf("Final Balance: %11.2f\n", p.TotalBalance())
+			fmt.Fprintf(summary, "Final Balance: %11.2f\n", p.TotalBalance())
 
 			if retirementPlan.RetirementPlan != nil {
 				date, ok := retirementPlan.RetirementPlan.RetireDate()
 				if ok {
-					cmd.Printf("Retirement date: %s\n", date.Format("2006-01-02"))
+					fmt.Fprintf(summary, "Retirement date: %s\n", date.Format("2006-01-02"))
 				} else {
-					cmd.Printf("Retirement date: could not find\n")
+					fmt.Fprintf(summary, "Retirement date: could not find\n")
 				}
 			}
 
@@ -112,7 +216,14 @@ var rootCmd = &cobra.Command{
 			w := watcher.New()
 			w.SetMaxEvents(1)
 			w.FilterOps(watcher.Write)
-			w.Add(fileName)
+			watchFiles := func() {
+				for _, f := range files {
+					if err := w.Add(f); err != nil {
+						cmd.Println(err)
+					}
+				}
+			}
+			watchFiles()
 
 			go func() {
 				for {
@@ -122,6 +233,7 @@ var rootCmd = &cobra.Command{
 						if err := run(); err != nil {
 							cmd.Println(err)
 						}
+						watchFiles()
 					case err := <-w.Error:
 						cmd.Println(err)
 					case <-w.Closed:
@@ -136,3 +248,27 @@ var rootCmd = &cobra.Command{
 		}
 	},
 }
+
+// parseFile parses a .munn file, returning its warnings along with the portfolio.
+func parseFile(fileName string) (*munn.Portfolio, munn.Diagnostics, error) {
+	p, diags := munn.ValidateFile(fileName)
+	if errs := diags.Errors(); len(errs) > 0 {
+		return nil, nil, errs
+	}
+	return p, diags.Warnings(), nil
+}
+
+func printWarnings(cmd *cobra.Command, warnings munn.Diagnostics) {
+	for _, w := range warnings {
+		cmd.PrintErrln(w)
+	}
+}
+
+func projectionYears(p *munn.Portfolio, flagYears int) int {
+	if flagYears != 0 {
+		return flagYears
+	} else if p.YearsToProject != nil {
+		return *p.YearsToProject
+	}
+	return 3
+}
@@ -1,12 +1,21 @@
 package main
 
 import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/Shamus03/munn"
 	"github.com/stretchr/testify/suite"
+	chart "github.com/wcharczuk/go-chart"
+	"github.com/xuri/excelize/v2"
 )
 
 type rootCmdSuite struct {
@@ -34,6 +43,18 @@ func (s *rootCmdSuite) run(args ...string) []string {
 	return strings.Split(strings.Trim(s.output.String(), "\n"), "\n")
 }
 
+// file writes a temporary .munn file with the given contents.
+func (s *rootCmdSuite) file(contents string) string {
+	return tempFile(s.T(), "example.munn", contents)
+}
+
+// example writes a copy of example.munn with extra YAML appended, so the extra YAML can use the example's anchors.
+func (s *rootCmdSuite) example(extra string) string {
+	example, err := ioutil.ReadFile("example.munn")
+	s.Require().Nil(err)
+	return s.file(string(example) + "\n" + extra)
+}
+
 func (s *rootCmdSuite) Test_Example() {
 	assert := s.Assert()
 	lines := s.run("example.munn")
@@ -90,3 +111,1517 @@ func (s *rootCmdSuite) Test_Example_Stats() {
 		assert.Equal("Average monthly growth", strings.Split(lines[2], ":")[0])
 	}
 }
+
+func (s *rootCmdSuite) Test_Stats() {
+	assert := s.Assert()
+	file := s.file(`
+yearsToProject: 1
+accounts:
+- id: 1
+  name: Bank
+- id: 2
+  name: Savings
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 10000
+transactions:
+- description: Paycheck
+  toAccount: 1
+  amount: 1000
+  schedule: Monthly(1)
+- description: Rent
+  fromAccount: 1
+  amount: 1250
+  schedule: Monthly(1)
+- description: Save
+  fromAccount: 1
+  toAccount: 2
+  amount: 50
+  schedule: Monthly(1)
+`)
+	lines := s.run(file, "--stats")
+
+	if assert.True(len(lines) > 8, "should have at least 8 lines") {
+		assert.Equal("Average monthly expenses:  $1250.00", lines[0])
+		assert.Equal("Average monthly income:    $1000.00", lines[1])
+		assert.Equal("Average monthly growth:    $-250.00", lines[2])
+		assert.Equal("Savings rate:              -25.00%", lines[3])
+		assert.Equal("Average monthly burn:      $250.00", lines[4])
+		assert.Equal("Runway:                    28.0 months", lines[5])
+		assert.Equal("Net monthly flow for Bank:  $-300.00 (income $1000.00, expenses $1250.00, transfers in $0.00, transfers out $50.00)", lines[6])
+		assert.Equal("Net monthly flow for Savings:  $50.00 (income $0.00, expenses $0.00, transfers in $50.00, transfers out $0.00)", lines[7])
+	}
+}
+
+func (s *rootCmdSuite) Test_Stats_ShortTransfer() {
+	assert := s.Assert()
+	file := s.file(`
+yearsToProject: 1
+accounts:
+- name: A
+- name: B
+- name: C
+manualAdjustments:
+- account: A
+  time: '2020-01-01'
+  balance: 10
+- account: B
+  time: '2020-01-01'
+  balance: 1000
+transactions:
+- description: Move
+  fromAccount: [A, B]
+  toAccount: C
+  amount: 100
+  schedule: Once(2020-06-01)
+`)
+	lines := s.run(file, "--stats")
+
+	// A is emptied but only the 90 from B gets to C, so the 10 from A is spent and the flows add up to the total falling by 10
+	assert.Contains(lines, "Net monthly flow for A:  $-0.83 (income $0.00, expenses $0.83, transfers in $0.00, transfers out $0.00)")
+	assert.Contains(lines, "Net monthly flow for B:  $-7.50 (income $0.00, expenses $0.00, transfers in $0.00, transfers out $7.50)")
+	assert.Contains(lines, "Net monthly flow for C:  $7.50 (income $0.00, expenses $0.00, transfers in $7.50, transfers out $0.00)")
+	assert.Contains(lines, "Final Balance:     1000.00")
+}
+
+func (s *rootCmdSuite) Test_Stats_ManualAdjustments() {
+	assert := s.Assert()
+	file := s.file(`
+yearsToProject: 2
+accounts:
+- id: 1
+  name: Bank
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 1000
+- account: 1
+  time: '2021-01-01'
+  balance: 2000
+transactions:
+- description: Paycheck
+  toAccount: 1
+  amount: 100
+  schedule: Monthly(1)
+`)
+	lines := s.run(file, "--stats")
+
+	// Only the months after the last manual adjustment are projected
+	if assert.True(len(lines) > 1, "should have at least 2 lines") {
+		assert.Equal("Average monthly income:    $100.00", lines[1])
+	}
+}
+
+func (s *rootCmdSuite) Test_AccountNames() {
+	assert := s.Assert()
+	file := s.file(`
+yearsToProject: 1
+accounts:
+- name: Bank
+- id: savings
+  name: Savings Account
+- id: 3
+  name: Investment
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 1000
+transactions:
+- description: Save
+  fromAccount: Bank
+  toAccount: savings
+  amount: 100
+  schedule: Monthly(1)
+- description: Invest
+  fromAccount: [Bank, savings]
+  toAccount: 3
+  amount: 150
+  schedule: Once(2020-06-01)
+`)
+	lines := s.run(file)
+
+	assert.Contains(lines, "2020-06-01\tBank\t350.00")
+	assert.Contains(lines, "2020-06-01\tSavings Account\t500.00")
+	assert.Contains(lines, "2020-06-01\tInvestment\t150.00")
+}
+
+func (s *rootCmdSuite) Test_AccountNames_NoAccount() {
+	file := s.file(`
+yearsToProject: 1
+accounts:
+- id: 1
+  name: Bank
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 1000
+transactions:
+- description: Rent
+  fromAccount: 1
+  toAccount: 0
+  amount: 100
+  schedule: Once(2020-06-01)
+`)
+	lines := s.run(file)
+
+	s.Assert().Contains(lines, "2020-06-01\tBank\t900.00")
+}
+
+func (s *rootCmdSuite) Test_AccountNames_Unknown() {
+	_, err := munn.Parse(strings.NewReader(`
+accounts:
+- id: savings
+  name: Bank
+transactions:
+- description: Save
+  fromAccount: Bnak
+  toAccount: saving
+  amount: 100
+  schedule: Monthly(1)
+`))
+	s.Assert().EqualError(err, "error: at least one manual adjustment is required to start the projection\n"+
+		"7:16: error: transaction 'Save' fromAccount: unknown account 'Bnak' (did you mean 'Bank'?)\n"+
+		"8:14: error: transaction 'Save' toAccount: unknown account 'saving' (did you mean 'savings'?)")
+
+	_, err = munn.Parse(strings.NewReader(`
+accounts:
+- id: 1
+  name: Bank
+manualAdjustments:
+- account: 7
+  time: '2020-01-01'
+  balance: 100
+`))
+	s.Assert().EqualError(err, "6:12: error: manual adjustment: unknown account '7'")
+}
+
+func (s *rootCmdSuite) Test_Validate() {
+	assert := s.Assert()
+	_, diags := munn.Validate(strings.NewReader(`
+accounts:
+- name: Bank
+  anualInterestRate: 0.01
+transactions:
+- description: Rent
+  fromAccount: Bank
+  amount: lots
+  schedule: Monthly(1)
+- description: Bonus
+  toAccount: Bank
+  amount: 100
+  schedule: Sometimes
+`))
+	assert.Equal("example.munn:4:3: error: unknown field 'anualInterestRate' (did you mean 'annualInterestRate'?)\n"+
+		"example.munn:8:11: error: cannot unmarshal !!str `lots` into float32\n"+
+		"example.munn:13:13: error: no schedule parser registered for name: Sometimes", diags.WithFile("example.munn").Error())
+
+	p, diags := munn.Validate(strings.NewReader(`
+accounts:
+- name: Bank
+- name: Savings
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 100
+transactions:
+- description: Refund
+  toAccount: Bank
+  amount: -10
+  schedule: Monthly(1)
+- description: Gift
+  toAccount: Bank
+  amount: 100
+  start: '2021-01-01'
+  stop: '2020-01-01'
+  schedule: Monthly(1)
+- description: Bonus
+  toAccount: Bank
+  amount: 100
+  stop: '2020-06-01'
+  schedule: Once(2020-07-01)
+`))
+	if assert.NotNil(p) {
+		assert.Empty(diags.Errors())
+		assert.Equal("4:3: warning: account 'Savings' is never used\n"+
+			"12:11: warning: transaction 'Refund' has a negative amount\n"+
+			"18:9: warning: transaction 'Gift' stops before it starts, so it never applies\n"+
+			"24:13: warning: transaction 'Bonus' is scheduled outside its start and stop, so it never applies", diags.Error())
+	}
+}
+
+// files writes temporary files with the given names and contents into one directory, and returns the directory.
+func (s *rootCmdSuite) files(files map[string]string) string {
+	return tempFiles(s.T(), files)
+}
+
+func (s *rootCmdSuite) Test_Include() {
+	assert := s.Assert()
+	dir := s.files(map[string]string{
+		"me.munn": `
+yearsToProject: 1
+include: [shared/household.munn]
+accounts:
+- name: Bank
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 1000
+transactions:
+- description: Household
+  fromAccount: Bank
+  toAccount: Joint
+  amount: 500
+  schedule: Monthly(15)
+`,
+		"shared/household.munn": `
+include: [bills.munn]
+accounts:
+- name: Joint
+manualAdjustments:
+- account: Joint
+  time: '2020-01-01'
+  balance: 0
+`,
+		"shared/bills.munn": `
+transactions:
+- description: Rent
+  fromAccount: Joint
+  amount: 400
+  schedule: Monthly(20)
+`,
+	})
+	lines := s.run(filepath.Join(dir, "me.munn"))
+
+	assert.Contains(lines, "2020-02-15\tBank\t500.00")
+	assert.Contains(lines, "2020-02-20\tJoint\t100.00")
+
+	p, err := munn.ParseFile(filepath.Join(dir, "me.munn"))
+	if assert.Nil(err) {
+		assert.Equal([]string{
+			filepath.Join(dir, "me.munn"),
+			filepath.Join(dir, "shared", "household.munn"),
+			filepath.Join(dir, "shared", "bills.munn"),
+		}, p.Files())
+	}
+}
+
+func (s *rootCmdSuite) Test_Include_Errors() {
+	dir := s.files(map[string]string{
+		"a.munn": `
+include: [b.munn]
+accounts:
+- id: 1
+  name: Bank
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 100
+`,
+		"b.munn": `
+include: [a.munn]
+accounts:
+- id: 1
+  name: Savings
+- name: Bank
+yearsToProject: 5
+`,
+	})
+	a, b := filepath.Join(dir, "a.munn"), filepath.Join(dir, "b.munn")
+	_, err := munn.ParseFile(a)
+
+	s.Assert().EqualError(err, b+":2:11: error: include cycle: "+a+" -> "+b+" -> "+a+"\n"+
+		b+":7:1: error: yearsToProject can only be in the main file, not an included file")
+
+	dir = s.files(map[string]string{
+		"a.munn": `
+include: [b.munn]
+accounts:
+- id: 1
+  name: Bank
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 100
+`,
+		"b.munn": `
+accounts:
+- id: 1
+  name: Savings
+- name: Bank
+`,
+	})
+	a, b = filepath.Join(dir, "a.munn"), filepath.Join(dir, "b.munn")
+	_, err = munn.ParseFile(a)
+
+	s.Assert().EqualError(err, b+":3:7: error: duplicate account ID: 1 (also used at "+a+":4:7)\n"+
+		b+":5:9: error: duplicate account name: Bank (also used at "+a+":5:9)")
+}
+
+func (s *rootCmdSuite) Test_Vars() {
+	assert := s.Assert()
+	file := s.file(`
+yearsToProject: 1
+vars:
+  salary: 52000
+  paycheck: =salary / 26
+  start: 2020-01-01
+  taxRate: 0.25
+accounts:
+- name: Bank
+  annualInterestRate: =taxRate / 5
+manualAdjustments:
+- account: Bank
+  time: =start
+  balance: =paycheck * 2
+transactions:
+- description: Paycheck
+  toAccount: Bank
+  amount: =paycheck * (1 - taxRate)
+  schedule: Monthly(15)
+  stop: =start + 2m + 1w
+`)
+	lines := s.run(file)
+
+	assert.Equal("2020-01-01\tBank\t4000.00", lines[0])
+	assert.Equal("2020-02-15\tBank\t5533.40", lines[2])
+	assert.Equal("2020-03-01\tBank\t5556.46", lines[3])
+	assert.Equal("2020-04-01\tBank\t5579.61", lines[4])
+}
+
+func (s *rootCmdSuite) Test_Vars_Errors() {
+	_, err := munn.Parse(strings.NewReader(`
+vars:
+  salary: 52000
+  a: =b + 1
+  b: =a * 2
+accounts:
+- name: Bank
+manualAdjustments:
+- account: Bank
+  time: =2020-01-01 + salray
+  balance: =salary / (2
+`))
+	s.Assert().EqualError(err, "5:6: error: variable 'b': variable cycle: a -> b -> a\n"+
+		"10:9: error: undefined variable 'salray' (did you mean 'salary'?)\n"+
+		"11:12: error: missing ')' in expression")
+}
+
+func (s *rootCmdSuite) Test_Marshal() {
+	assert := s.Assert()
+	require := s.Require()
+	expected := s.run("example.munn")
+
+	p, err := munn.ParseFile("example.munn")
+	require.Nil(err)
+	b, err := munn.Marshal(p)
+	require.Nil(err)
+	assert.Contains(string(b), "schedule: Weekly(Thursday)")
+	assert.Contains(string(b), "schedule: Monthly(10)")
+	assert.Contains(string(b), "schedule: Once(2020-12-08)")
+
+	// Parsing the marshaled portfolio gives the same projection, and marshals the same again
+	p2, err := munn.Parse(bytes.NewReader(b))
+	require.Nil(err)
+	b2, err := munn.Marshal(p2)
+	require.Nil(err)
+	assert.Equal(string(b), string(b2))
+
+	s.output.Reset()
+	assert.Equal(expected, s.run(s.file(string(b))))
+}
+
+func (s *rootCmdSuite) Test_Marshal_Features() {
+	assert := s.Assert()
+	require := s.Require()
+	file := s.file(`
+yearsToProject: 2
+rmd:
+  startAge: 75
+  table: {75: 20}
+accounts:
+- id: 1
+  name: Bank
+  group: Cash
+  tags: [Liquid]
+- id: 2
+  name: Bank
+  taxTreatment: taxDeferred
+  costBasis: fifo
+  contributionLimit: 1000
+  ownerBirthDate: 1950-01-01
+  rmdAccount: 1
+manualAdjustments:
+- account: 1
+  time: 2020-01-01
+  balance: 10000
+- account: 2
+  time: 2020-01-01
+  balance: 5000
+  basis: 4000
+transactions:
+- fromAccount: [1, 2]
+  toAccount: 2
+  description: 401k
+  schedule: Biweekly(Friday)
+  salary: Bonus
+  salaryRate: 0.3
+  start: 2020-02-01T12:00:00Z
+  match: {rate: 0.5, upTo: 0.06}
+- toAccount: 1
+  description: Bonus
+  schedule: Yearly(December 15)
+  amount: 1000
+  growth: 0.03
+  taxable: true
+pensions:
+- toAccount: 1
+  description: Pension
+  benefit: 1000
+  birthDate: 1950-01-01
+  claimDate: 2020-06-01
+grants:
+- toAccount: 1
+  description: RSU
+  shares: 100
+  price: 10
+  start: 2020-01-01
+  vestingMonths: 12
+rebalances:
+- description: Yearly
+  schedule: Yearly(July 1)
+  targets:
+  - {account: 1, percent: 0.5}
+  - {account: 2, percent: 0.5}
+taxes:
+  account: 1
+  years:
+  - year: 2020
+    brackets:
+    - {over: 0, rate: 0.1}
+`)
+	expected := s.run(file)
+
+	p, err := munn.ParseFile(file)
+	require.Nil(err)
+	b, err := munn.Marshal(p)
+	require.Nil(err)
+	assert.Contains(string(b), "id: 2")
+	assert.Contains(string(b), "start: \"2020-02-01T12:00:00Z\"")
+	assert.Contains(string(b), "salary: Bonus")
+	assert.Contains(string(b), "salaryRate: 0.3")
+
+	s.output.Reset()
+	assert.Equal(expected, s.run(s.file(string(b))))
+}
+
+const syntaxYAML = `
+accounts:
+- id: 1
+  name: Bank
+  annualInterestRate: 0.06
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 1000
+transactions:
+- toAccount: 1
+  description: Paycheck
+  schedule: Monthly(15)
+  amount: 1_000
+  start: 2020-01-01T00:00:00Z
+`
+
+const syntaxJSON = `{
+  "accounts": [{"id": 1, "name": "Bank", "annualInterestRate": 0.06}],
+  "manualAdjustments": [{"account": 1, "time": "2020-01-01", "balance": 1000}],
+  "transactions": [{"toAccount": 1, "description": "Paycheck", "schedule": "Monthly(15)", "amount": 1000, "start": "2020-01-01T00:00:00Z"}]
+}`
+
+const syntaxTOML = `
+[[accounts]]
+id = 1
+name = "Bank"
+annualInterestRate = 0.06
+
+[[manualAdjustments]]
+account = 1
+time = 2020-01-01
+balance = 1000
+
+[[transactions]]
+toAccount = 1
+description = "Paycheck"
+schedule = "Monthly(15)"
+amount = 1_000
+start = 2020-01-01 00:00:00Z
+`
+
+func (s *rootCmdSuite) Test_Syntax() {
+	assert := s.Assert()
+	dir := s.files(map[string]string{
+		"portfolio.munn": syntaxYAML,
+		"portfolio.json": syntaxJSON,
+		"portfolio.toml": syntaxTOML,
+	})
+	expected := s.run(filepath.Join(dir, "portfolio.munn"), "--years", "1")
+	assert.Contains(expected, "2020-02-15\tBank\t2010.03")
+
+	s.output.Reset()
+	assert.Equal(expected, s.run(filepath.Join(dir, "portfolio.json"), "--years", "1"))
+	s.output.Reset()
+	assert.Equal(expected, s.run(filepath.Join(dir, "portfolio.toml"), "--years", "1"))
+
+	_, err := munn.ParseJSON(strings.NewReader(syntaxJSON))
+	assert.Nil(err)
+	_, err = munn.ParseTOML(strings.NewReader(syntaxTOML))
+	assert.Nil(err)
+}
+
+func (s *rootCmdSuite) Test_Syntax_TOML_Errors() {
+	assert := s.Assert()
+	_, err := munn.ParseTOML(strings.NewReader(`
+[[accounts]]
+name = "Bank"
+anualInterestRate = 0.01
+
+[[manualAdjustments]]
+account = "Bank"
+time = 2020-01-01
+balance = "lots"
+
+[[transactions]]
+toAccount = "Bnak"
+description = "Paycheck"
+schedule = "Weekly(Someday)"
+amount = 100
+`))
+	assert.EqualError(err, "4:1: error: unknown field 'anualInterestRate' (did you mean 'annualInterestRate'?)\n"+
+		"9:11: error: cannot unmarshal !!str `lots` into float32\n"+
+		"14:12: error: error parsing schedule: invalid weekday: Someday")
+
+	_, err = munn.ParseTOML(strings.NewReader("accounts = 1\naccounts = 2\n"))
+	assert.EqualError(err, "2:1: error: key accounts is already defined")
+}
+
+func (s *rootCmdSuite) Test_Syntax_Include() {
+	assert := s.Assert()
+	dir := s.files(map[string]string{
+		"main.munn": `
+include: [accounts.toml, bills.json]
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 1000
+`,
+		"accounts.toml": `
+[[accounts]]
+name = "Bank"
+`,
+		"bills.json": `{"transactions": [{"fromAccount": "Bank", "description": "Rent", "schedule": "Monthly(1)", "amount": 100}]}`,
+	})
+	lines := s.run(filepath.Join(dir, "main.munn"), "--years", "1")
+
+	assert.Contains(lines, "2020-03-01\tBank\t800.00")
+}
+
+func (s *rootCmdSuite) Test_Schema() {
+	assert := s.Assert()
+	s.run("schema")
+
+	var schema struct {
+		Title      string
+		Properties map[string]struct {
+			Items struct {
+				Required []string
+			}
+		}
+	}
+	s.Require().Nil(json.Unmarshal([]byte(s.output.String()), &schema))
+	assert.Equal("munn portfolio", schema.Title)
+	assert.Contains(schema.Properties, "accounts")
+	assert.Equal([]string{"schedule"}, schema.Properties["transactions"].Items.Required)
+}
+
+func (s *rootCmdSuite) Test_Example_Pension() {
+	assert := s.Assert()
+	file := s.example(`
+pensions:
+- toAccount: *bank
+  description: Social Security
+  benefit: 1500
+  birthDate: '1990-06-15'
+  claimDate: '2055-06-15'
+  cola: 0.02
+`)
+	lines := s.run(file, "--retire", "2080-01-01:25000", "--years", "100")
+
+	if assert.NotEmpty(lines) {
+		// The example alone retires in 2068, so the pension should allow retiring sooner
+		assert.Equal("Retirement date: 2052-04-25", lines[len(lines)-1])
+	}
+}
+
+func (s *rootCmdSuite) Test_RMD() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Bank
+- id: 2
+  name: IRA
+  taxTreatment: taxDeferred
+  ownerBirthDate: '1945-06-01'
+  rmdAccount: 1
+manualAdjustments:
+- account: 2
+  time: '2020-01-01'
+  balance: 100000
+`)
+	lines := s.run(file, "--years", "1")
+
+	// Age 75 has a distribution period of 24.6, and age 76 has 23.7
+	assert.Contains(lines, "2020-02-01\tBank\t4065.04")
+	assert.Contains(lines, "2020-02-01\tIRA\t95934.96")
+	assert.Contains(lines, "2021-01-01\tBank\t8112.93")
+	assert.Contains(lines, "2021-01-01\tIRA\t91887.07")
+}
+
+func (s *rootCmdSuite) Test_Taxes() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Bank
+- id: 2
+  name: IRA
+  taxTreatment: taxDeferred
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 0
+- account: 2
+  time: '2020-01-01'
+  balance: 100000
+transactions:
+- toAccount: 1
+  description: Paycheck
+  schedule: Monthly(1)
+  amount: 5000
+  taxable: true
+- fromAccount: 1
+  description: Traditional IRA contribution
+  schedule: Once(2020-06-01)
+  amount: 6000
+  deductible: true
+- fromAccount: 2
+  toAccount: 1
+  description: IRA withdrawal
+  schedule: Once(2021-01-01)
+  amount: 10000
+taxes:
+  account: 1
+  inflation: 0.1
+  years:
+  - year: 2020
+    standardDeduction: 4000
+    brackets:
+    - over: 0
+      rate: 0.1
+    - over: 40000
+      rate: 0.2
+`)
+	lines := s.run(file, "--years", "1", "--taxes", "--image")
+
+	if assert.True(len(lines) > 3, "should have at least 3 lines") {
+		assert.Equal("Year\tIncome\tDeductions\tTaxable income\tTax\tEffective rate\tMarginal rate\tWithheld", lines[0])
+		// January's paycheck is already in the manual adjustment, so 55000 income - 6000 deducted - 4000 standard deduction
+		// = 45000 taxable, which is 40000 at 10% plus 5000 at 20%
+		assert.Equal("2020\t55000.00\t6000.00\t45000.00\t5000.00\t9.09%\t20.00%\t0.00", lines[1])
+		// The withdrawal from the IRA is income, and the brackets are indexed by 10%
+		assert.Equal("2021\t15000.00\t0.00\t10600.00\t1060.00\t7.07%\t10.00%\t0.00", lines[2])
+	}
+}
+
+func (s *rootCmdSuite) Test_ShortTransfer() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- name: A
+- name: B
+- name: C
+manualAdjustments:
+- account: A
+  time: '2020-01-01'
+  balance: 50
+- account: B
+  time: '2020-01-01'
+  balance: 80
+- account: C
+  time: '2020-01-01'
+  balance: 0
+transactions:
+- description: Move
+  fromAccount: [A, B]
+  toAccount: C
+  schedule: Once(2020-03-01)
+  amount: 100
+- description: Move again
+  fromAccount: [A, B]
+  toAccount: C
+  schedule: Once(2020-04-01)
+  amount: 100
+`)
+	lines := s.run(file, "--years", "1")
+
+	// A is emptied and the rest comes from B, but only the part from B reaches C
+	assert.Contains(lines, "2020-03-01\tA\t0.00")
+	assert.Contains(lines, "2020-03-01\tB\t30.00")
+	assert.Contains(lines, "2020-03-01\tC\t50.00")
+	// Neither account has enough, so both are emptied and nothing reaches C
+	assert.Contains(lines, "2020-04-01\tB\t0.00")
+	assert.Contains(lines, "2020-04-01\tC\t50.00")
+}
+
+func (s *rootCmdSuite) Test_Gains() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Bank
+- id: 2
+  name: Brokerage
+  annualInterestRate: 0.12
+  costBasis: fifo
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 0
+- account: 2
+  time: '2020-01-01'
+  balance: 10000
+  basis: 5000
+transactions:
+- fromAccount: 2
+  toAccount: 1
+  description: Sell
+  schedule: Once(2020-01-15)
+  amount: 1000
+- fromAccount: 1
+  toAccount: 2
+  description: Buy
+  schedule: Once(2020-09-01)
+  amount: 1000
+- fromAccount: 2
+  toAccount: 1
+  description: Sell again
+  schedule: Once(2021-07-01)
+  amount: 20000
+`)
+	lines := s.run(file, "--years", "2", "--gains", "--image")
+
+	if assert.True(len(lines) > 3, "should have at least 3 lines") {
+		assert.Equal("Year\tShort-term gains\tLong-term gains", lines[0])
+		// Half of the first lot is gains, after a month of interest
+		assert.Equal("2020\t504.95\t0.00", lines[1])
+		// The first lot is long-term, and the lot bought in 2020 is short-term
+		assert.True(strings.HasPrefix(lines[2], "2021\t"), lines[2])
+		spl := strings.Split(lines[2], "\t")
+		shortTerm, _ := strconv.ParseFloat(spl[1], 32)
+		longTerm, _ := strconv.ParseFloat(spl[2], 32)
+		assert.True(shortTerm > 0 && shortTerm < 200, lines[2])
+		assert.True(longTerm > 4500, lines[2])
+	}
+}
+
+func (s *rootCmdSuite) Test_Gains_ManualAdjustments() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Bank
+- id: 2
+  name: Brokerage
+  costBasis: proportional
+manualAdjustments:
+- account: 1
+  time: '2019-01-01'
+  balance: 0
+- account: 2
+  time: '2019-01-01'
+  balance: 1000
+  basis: 100
+- account: 1
+  time: '2020-01-01'
+  balance: 0
+- account: 2
+  time: '2020-01-01'
+  balance: 1000
+  basis: 1000
+transactions:
+- fromAccount: 2
+  toAccount: 1
+  description: Sell
+  schedule: Once(2020-01-01)
+  amount: 500
+- toAccount: 1
+  description: Paycheck
+  schedule: Once(2019-06-01)
+  amount: 10000
+  taxable: true
+taxes:
+  years:
+  - year: 2019
+    brackets:
+    - over: 0
+      rate: 0.1
+`)
+	lines := s.run(file, "--years", "1", "--gains", "--taxes")
+
+	// The sale and the paycheck are already in the 2020 manual adjustments, so they aren't gains or taxable income
+	assert.Equal("Year\tIncome\tDeductions\tTaxable income\tTax\tEffective rate\tMarginal rate\tWithheld", lines[0])
+	assert.True(strings.HasPrefix(lines[1], "2020\t0.00\t"), lines[1])
+	assert.Contains(lines, "Year\tShort-term gains\tLong-term gains")
+	for _, line := range lines {
+		assert.False(strings.HasPrefix(line, "2019\t"), line)
+		assert.NotContains(line, "450.00")
+	}
+}
+
+func (s *rootCmdSuite) Test_ContributionLimit() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Bank
+- id: 2
+  name: 401k
+  contributionLimit: 1000
+  contributionLimitGrowth: 0.1
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 10000
+- account: 2
+  time: '2020-01-01'
+  balance: 0
+transactions:
+- toAccount: 1
+  description: Pay
+  schedule: Monthly(1)
+  amount: 2000
+- fromAccount: 1
+  toAccount: 2
+  description: 401k contribution
+  schedule: Monthly(1)
+  start: '2020-02-01'
+  salary: Pay
+  salaryRate: 0.15
+  match:
+    rate: 0.5
+    upTo: 0.1
+`)
+	lines := s.run(file, "--years", "1")
+
+	// Contributions stop at 1000, and the match is 100 for each full contribution (up to 10% of the pay) and 50 for the partial one
+	assert.Contains(lines, "2020-12-01\tBank\t31000.00")
+	assert.Contains(lines, "2020-12-01\t401k\t1350.00")
+	// Contributions and the match start again in the next year
+	assert.Contains(lines, "2021-01-01\t401k\t1750.00")
+}
+
+func (s *rootCmdSuite) Test_EmployerMatch() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Bank
+- id: 2
+  name: 401k
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 0
+- account: 2
+  time: '2020-01-01'
+  balance: 0
+transactions:
+- toAccount: 1
+  description: Salary
+  schedule: Monthly(1)
+  amount: 2000
+  growth: 0.1
+- fromAccount: 1
+  toAccount: 2
+  description: 401k
+  schedule: Monthly(1)
+  salary: Salary
+  salaryRate: 0.1
+  match:
+    rate: 0.5
+    upTo: 0.06
+`)
+	lines := s.run(file, "--years", "2")
+
+	// Contribute 10% of the salary, and the employer matches 50% of the contributions up to 6% of the salary
+	assert.Contains(lines, "2020-02-01\tBank\t1800.00")
+	assert.Contains(lines, "2020-02-01\t401k\t260.00")
+	// Contributions and the match follow the salary as it grows
+	assert.Contains(lines, "2021-01-01\tBank\t21780.00")
+	assert.Contains(lines, "2021-01-01\t401k\t3146.00")
+}
+
+func (s *rootCmdSuite) Test_EmployerMatch_Errors() {
+	assert := s.Assert()
+	_, diags := munn.Validate(strings.NewReader(`
+accounts:
+- name: Bank
+- name: 401k
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 0
+transactions:
+- toAccount: 401k
+  description: Fixed
+  schedule: Monthly(1)
+  amount: 100
+  match: {rate: 0.5, upTo: 0.06}
+- toAccount: 401k
+  description: Share
+  schedule: Monthly(1)
+  salary: Missing
+  salaryRate: 0.1
+- toAccount: 401k
+  description: Both
+  schedule: Monthly(1)
+  amount: 100
+  salary: Fixed
+  salaryRate: 0.1
+- toAccount: 401k
+  description: Chain
+  schedule: Monthly(1)
+  salary: Share
+  salaryRate: 0.1
+`))
+	var lines []string
+	for _, d := range diags.Errors() {
+		lines = append(lines, d.Message)
+	}
+	assert.Equal([]string{
+		"transaction 'Fixed' with a match upTo is missing salary (upTo is a share of the salary)",
+		"transaction 'Share' salary: no transaction with description 'Missing'",
+		"transaction 'Both' has both an amount and a salaryRate",
+		"transaction 'Chain' salary: 'Share' is itself a share of a salary",
+	}, lines)
+}
+
+func (s *rootCmdSuite) Test_Grants() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Investment
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 0
+grants:
+- toAccount: 1
+  description: RSU
+  shares: 400
+  price: 10
+  start: '2020-01-01'
+  cliffMonths: 12
+  vestingMonths: 24
+  intervalMonths: 6
+  withholding: 0.25
+`)
+	lines := s.run(file, "--years", "2")
+
+	// Half of the shares vest at the cliff, then a quarter every 6 months
+	assert.Contains(lines, "2021-01-01\tInvestment\t1500.00\tRSU vest 1/3 (200.00 shares @ $10.00)")
+	assert.Contains(lines, "2021-07-01\tInvestment\t2250.00\tRSU vest 2/3 (100.00 shares @ $10.00)")
+	assert.Contains(lines, "2022-01-01\tInvestment\t3000.00\tRSU vest 3/3 (100.00 shares @ $10.00)")
+}
+
+func (s *rootCmdSuite) Test_Grants_Taxes() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Bank
+- id: 2
+  name: Investment
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 10000
+- account: 2
+  time: '2020-01-01'
+  balance: 0
+grants:
+- toAccount: 2
+  description: RSU
+  shares: 100
+  price: 100
+  start: '2020-01-01'
+  cliffMonths: 6
+  vestingMonths: 6
+  intervalMonths: 6
+  withholding: 0.1
+  taxable: true
+- toAccount: 2
+  description: Options
+  shares: 100
+  price: 10
+  start: '2020-01-01'
+  cliffMonths: 6
+  vestingMonths: 6
+  intervalMonths: 6
+taxes:
+  account: 1
+  years:
+  - year: 2020
+    brackets:
+    - over: 0
+      rate: 0.1
+    - over: 5000
+      rate: 0.3
+`)
+	lines := s.run(file, "--years", "1", "--taxes")
+
+	// The RSU's whole 10000 is income, taxed 500 + 1500, and the 1000 withheld is already paid; the options aren't taxable
+	assert.Equal("Year\tIncome\tDeductions\tTaxable income\tTax\tEffective rate\tMarginal rate\tWithheld", lines[0])
+	assert.Equal("2020\t10000.00\t0.00\t10000.00\t2000.00\t20.00%\t30.00%\t1000.00", lines[1])
+	assert.Contains(lines, "2020-07-01\tInvestment\t10000.00\tRSU vest 1/1 (100.00 shares @ $100.00)\tOptions vest 1/1 (100.00 shares @ $10.00)")
+	assert.Contains(lines, "2021-01-01\tBank\t9000.00")
+
+	// Withholding more than the tax is refunded
+	overwithheld, err := ioutil.ReadFile(file)
+	s.Require().Nil(err)
+	s.output.Reset()
+	lines = s.run(s.file(strings.Replace(string(overwithheld), "withholding: 0.1", "withholding: 0.25", 1)), "--years", "1", "--taxes")
+	assert.Equal("2020\t10000.00\t0.00\t10000.00\t2000.00\t20.00%\t30.00%\t2500.00", lines[1])
+	assert.Contains(lines, "2021-01-01\tBank\t10500.00")
+}
+
+func (s *rootCmdSuite) Test_Rebalance() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Stocks
+  annualInterestRate: 0.12
+- id: 2
+  name: Bonds
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 5000
+- account: 2
+  time: '2020-01-01'
+  balance: 5000
+rebalances:
+- description: Yearly
+  schedule: Yearly(July 1)
+  tolerance: 0.05
+  targets:
+  - account: 1
+    percent: 0.6
+  - account: 2
+    percent: 0.4
+`)
+	lines := s.run(file, "--years", "1")
+
+	// Rebalance to 60/40 right away (after the first month of interest), then stocks don't drift far enough by July to rebalance again
+	assert.Contains(lines, "2020-02-01\tBonds\t4020.00")
+	assert.Contains(lines, "2020-12-01\tBonds\t4020.00")
+}
+
+func (s *rootCmdSuite) Test_GroupBy() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Checking
+  group: Cash
+  tags: [Liquid]
+- id: 2
+  name: Savings
+  group: Cash
+  tags: [Liquid, Emergency]
+- id: 3
+  name: IRA
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 100
+- account: 2
+  time: '2020-01-01'
+  balance: 200
+- account: 3
+  time: '2020-01-01'
+  balance: 400
+`)
+	lines := s.run(file, "--years", "1", "--group-by", "group")
+	assert.Contains(lines, "2020-01-01\tCash\t300.00")
+	assert.Contains(lines, "2020-01-01\tOther\t400.00")
+	assert.NotContains(lines, "2020-01-01\tChecking\t100.00")
+
+	s.output.Reset()
+	lines = s.run(file, "--years", "1", "--group-by", "tag")
+	assert.Contains(lines, "2020-01-01\tLiquid\t300.00")
+	assert.Contains(lines, "2020-01-01\tEmergency\t200.00")
+	assert.Contains(lines, "2020-01-01\tOther\t400.00")
+}
+
+func (s *rootCmdSuite) Test_GroupBy_SameName() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- id: 1
+  name: Bank
+  group: Cash
+- id: 2
+  name: Bank
+  group: Savings
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 100
+- account: 2
+  time: '2020-01-01'
+  balance: 200
+`)
+	// Accounts with the same name are still their own records
+	lines := s.run(file, "--years", "1")
+	assert.Equal([]string{"2020-01-01\tBank\t100.00", "2020-01-01\tBank\t200.00"}, lines[:2])
+
+	s.output.Reset()
+	lines = s.run(file, "--years", "1", "--group-by", "group")
+	assert.Equal([]string{"2020-01-01\tCash\t100.00", "2020-01-01\tSavings\t200.00"}, lines[:2])
+
+	// Each account has its own series in the image too, stacked on the one before it
+	p, err := munn.ParseFile(file)
+	s.Require().Nil(err)
+	recs := p.Project(1)
+	graph := p.Chart(recs)
+	if assert.Len(graph.Series, 2) {
+		for i, balance := range []float64{300, 100} {
+			series := graph.Series[i].(*chart.TimeSeries)
+			assert.Equal("Bank", series.Name)
+			assert.Len(series.XValues, len(recs)/2)
+			assert.Equal(balance, series.YValues[0])
+		}
+	}
+}
+
+const formatPortfolio = `
+accounts:
+- name: Bank, Main
+manualAdjustments:
+- account: Bank, Main
+  time: '2020-01-01'
+  balance: 100
+grants:
+- toAccount: Bank, Main
+  description: RSU
+  shares: 10
+  price: 10
+  start: '2020-01-01'
+  cliffMonths: 1
+  vestingMonths: 2
+  intervalMonths: 1
+`
+
+func (s *rootCmdSuite) Test_Format() {
+	assert := s.Assert()
+	file := s.file(formatPortfolio)
+	summary := new(strings.Builder)
+	rootCmd.SetErr(summary)
+
+	lines := s.run(file, "--years", "1", "--format", "csv")
+	assert.Equal("time,account,balance,events", lines[0])
+	assert.Equal(`2020-01-01,"Bank, Main",100.00,`, lines[1])
+	assert.Equal(`2020-02-01,"Bank, Main",150.00,RSU vest 1/2 (5.00 shares @ $10.00)`, lines[2])
+	assert.NotContains(s.output.String(), "Final Balance")
+	assert.Equal("Final Balance:      200.00\n", summary.String())
+
+	s.output.Reset()
+	lines = s.run(file, "--years", "1", "--format", "tsv", "--columns", "account,balance")
+	assert.Equal("account\tbalance", lines[0])
+	assert.Equal("Bank, Main\t100.00", lines[1])
+}
+
+func (s *rootCmdSuite) Test_Format_Output() {
+	assert := s.Assert()
+	file := s.file(formatPortfolio)
+	output := filepath.Join(filepath.Dir(file), "projection.csv")
+
+	lines := s.run(file, "--years", "1", "--format", "csv", "--columns", "time,balance", "--output", output)
+	assert.Equal([]string{"Final Balance:      200.00"}, lines)
+
+	written, err := ioutil.ReadFile(output)
+	s.Require().Nil(err)
+	assert.True(strings.HasPrefix(string(written), "time,balance\n2020-01-01,100.00\n2020-02-01,150.00\n"), string(written))
+}
+
+func (s *rootCmdSuite) Test_Output_Image() {
+	file := s.file(formatPortfolio)
+	output := filepath.Join(filepath.Dir(file), "projection.csv")
+
+	s.run(file, "--years", "1", "--image", "--format", "csv", "--output", output)
+
+	_, err := os.Stat(output)
+	s.Assert().True(os.IsNotExist(err), "the output file shouldn't be created for an image")
+}
+
+func (s *rootCmdSuite) Test_Format_JSON() {
+	assert := s.Assert()
+	file := s.file(formatPortfolio)
+	rootCmd.SetErr(new(strings.Builder))
+
+	s.run(file, "--years", "1", "--format", "json", "--retire", "2020-01-01:1")
+	var doc struct {
+		Records        []munn.ProjectionRecord
+		FinalBalance   float32
+		RetirementDate *time.Time
+		Stats          map[string]interface{}
+		Warnings       munn.Diagnostics
+	}
+	s.Require().Nil(json.Unmarshal([]byte(s.output.String()), &doc))
+	s.Require().NotEmpty(doc.Records)
+	assert.Equal(munn.ProjectionRecord{Time: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), AccountName: "Bank, Main", Balance: 100}, doc.Records[0])
+	assert.Equal([]string{"RSU vest 1/2 (5.00 shares @ $10.00)"}, doc.Records[1].Events)
+	assert.Equal(float32(200), doc.FinalBalance)
+	s.Require().NotNil(doc.RetirementDate)
+	assert.Equal("2020-01-01", doc.RetirementDate.Format("2006-01-02"))
+	assert.Contains(doc.Stats, "averageMonthlyIncome")
+	// There is no burn, so the runway is indefinite
+	assert.Nil(doc.Stats["runwayMonths"])
+	assert.Equal(munn.Diagnostics{}, doc.Warnings)
+
+	s.output.Reset()
+	lines := s.run(file, "--years", "1", "--format", "ndjson")
+	assert.Equal(`{"time":"2020-01-01T00:00:00Z","account":"Bank, Main","balance":100}`, lines[0])
+	assert.Equal(`{"time":"2020-02-01T00:00:00Z","account":"Bank, Main","balance":150,"events":["RSU vest 1/2 (5.00 shares @ $10.00)"]}`, lines[1])
+}
+
+func (s *rootCmdSuite) Test_Wide() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- name: Checking
+  group: Cash
+- name: Savings
+  group: Cash
+- name: IRA
+manualAdjustments:
+- account: Checking
+  time: '2020-01-01'
+  balance: 100
+- account: Savings
+  time: '2020-01-01'
+  balance: 200
+- account: IRA
+  time: '2020-01-01'
+  balance: 400
+- account: IRA
+  time: '2020-02-01'
+  balance: 500
+`)
+	lines := s.run(file, "--years", "1", "--wide")
+	assert.Equal("Time\tChecking\tSavings\tIRA\tTotal", lines[0])
+	assert.Equal("2020-01-01\t100.00\t200.00\t400.00\t700.00", lines[1])
+	assert.Equal("2020-02-01\t100.00\t200.00\t500.00\t800.00", lines[2])
+
+	s.output.Reset()
+	rootCmd.SetErr(new(strings.Builder))
+	lines = s.run(file, "--years", "1", "--wide", "--group-by", "group", "--format", "csv")
+	assert.Equal("Time,Checking,Savings,IRA,Cash,Other,Total", lines[0])
+	assert.Equal("2020-01-01,100.00,200.00,400.00,300.00,400.00,700.00", lines[1])
+}
+
+func (s *rootCmdSuite) Test_Wide_SameName() {
+	file := s.file(`
+accounts:
+- id: 1
+  name: Bank
+- id: 2
+  name: Bank
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 100
+- account: 2
+  time: '2020-01-01'
+  balance: 200
+`)
+	lines := s.run(file, "--years", "1", "--wide")
+	s.Assert().Equal([]string{"Time\tBank\tBank\tTotal", "2020-01-01\t100.00\t200.00\t300.00"}, lines[:2])
+}
+
+func (s *rootCmdSuite) Test_Interval() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- name: Bank
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 1000
+transactions:
+- fromAccount: Bank
+  description: Rent
+  schedule: Monthly(16)
+  amount: 100
+- toAccount: Bank
+  description: Paycheck
+  schedule: Monthly(1)
+  amount: 300
+`)
+	// The balance is 1300 from February 1st and 1200 from February 16th
+	lines := s.run(file, "--years", "1", "--interval", "month")
+	assert.Equal("2020-01-01\tBank\t1000.00", lines[0])
+	assert.Equal("2020-02-01\tBank\t1200.00", lines[1])
+	assert.Equal("2020-12-01\tBank\t3200.00", lines[11])
+	assert.Equal("2021-01-01\tBank\t3500.00", lines[12])
+	assert.Equal("Final Balance:     3500.00", lines[13])
+
+	s.output.Reset()
+	lines = s.run(file, "--years", "1", "--interval", "month", "--sample", "min")
+	assert.Equal("2020-02-01\tBank\t1200.00", lines[1])
+	s.output.Reset()
+	lines = s.run(file, "--years", "1", "--interval", "month", "--sample", "max")
+	assert.Equal("2020-02-01\tBank\t1300.00", lines[1])
+	s.output.Reset()
+	// February 2020 has 15 days at 1300 and 14 at 1200
+	lines = s.run(file, "--years", "1", "--interval", "month", "--sample", "average")
+	assert.Equal("2020-02-01\tBank\t1251.72", lines[1])
+
+	s.output.Reset()
+	lines = s.run(file, "--years", "1", "--interval", "quarter", "--sample", "end")
+	assert.Equal([]string{
+		"2020-01-01\tBank\t1400.00",
+		"2020-04-01\tBank\t2000.00",
+		"2020-07-01\tBank\t2600.00",
+		"2020-10-01\tBank\t3200.00",
+		"2021-01-01\tBank\t3500.00",
+		"Final Balance:     3500.00",
+	}, lines)
+}
+
+func (s *rootCmdSuite) Test_Interval_Gaps() {
+	file := s.file(`
+accounts:
+- id: 1
+  name: Bank
+- id: 2
+  name: Bank
+manualAdjustments:
+- account: 1
+  time: '2020-01-01'
+  balance: 100
+- account: 2
+  time: '2020-01-01'
+  balance: 200
+transactions:
+- toAccount: 1
+  description: Bonus
+  schedule: Once(2020-04-15)
+  amount: 50
+`)
+	// Months without any changes carry the balances forward, and accounts with the same name are kept apart
+	lines := s.run(file, "--years", "1", "--interval", "month")
+	s.Assert().Equal([]string{
+		"2020-01-01\tBank\t100.00",
+		"2020-01-01\tBank\t200.00",
+		"2020-02-01\tBank\t100.00",
+		"2020-02-01\tBank\t200.00",
+		"2020-03-01\tBank\t100.00",
+		"2020-03-01\tBank\t200.00",
+		"2020-04-01\tBank\t150.00",
+		"2020-04-01\tBank\t200.00",
+	}, lines[:8])
+}
+
+func (s *rootCmdSuite) Test_Format_XLSX() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- name: Checking
+  group: Cash
+- name: IRA
+manualAdjustments:
+- account: Checking
+  time: '2020-01-01'
+  balance: 100
+- account: IRA
+  time: '2020-01-01'
+  balance: 400
+transactions:
+- description: Paycheck
+  toAccount: Checking
+  schedule: Monthly(1)
+  amount: 1000
+`)
+	output := filepath.Join(filepath.Dir(file), "projection.xlsx")
+
+	lines := s.run(file, "--years", "1", "--group-by", "group", "--format", "xlsx", "--output", output, "--retire", "2020-01-01:1")
+	assert.Equal("Wrote workbook to "+output, lines[0])
+
+	f, err := excelize.OpenFile(output)
+	s.Require().Nil(err)
+	defer f.Close()
+	assert.Equal([]string{"Projection", "Transactions", "Stats", "Chart"}, f.GetSheetList())
+
+	rows, err := f.GetRows("Projection")
+	s.Require().Nil(err)
+	assert.Equal([]string{"Time", "Checking", "IRA", "Cash", "Other", "Total"}, rows[0])
+	// Totals are formulas, which are only calculated when the workbook is opened
+	assert.Equal([]string{"2020-01-01", "$100.00", "$400.00", "$100.00", "$400.00", ""}, rows[1])
+	assert.Equal([]string{"2020-02-01", "$1,100.00", "$400.00", "$1,100.00", "$400.00", ""}, rows[2])
+	formula, err := f.GetCellFormula("Projection", "F3")
+	s.Require().Nil(err)
+	assert.Equal("SUM(B3:C3)", formula)
+	// Dates and balances are numbers formatted by their cells, not text
+	raw, err := f.GetCellValue("Projection", "A2", excelize.Options{RawCellValue: true})
+	s.Require().Nil(err)
+	assert.Equal("43831", raw)
+	raw, err = f.GetCellValue("Projection", "B3", excelize.Options{RawCellValue: true})
+	s.Require().Nil(err)
+	assert.Equal("1100", raw)
+
+	rows, err = f.GetRows("Transactions")
+	s.Require().Nil(err)
+	assert.Equal([]string{"Description", "Category", "From", "To", "Amount", "Schedule", "Per year", "Start", "Stop"}, rows[0])
+	assert.Equal([]string{"Paycheck", "", "", "Checking", "$1,000.00", "Monthly(1)", "$12,000.00"}, rows[1])
+
+	rows, err = f.GetRows("Stats")
+	s.Require().Nil(err)
+	assert.Equal([]string{"Final balance", "$12,500.00"}, rows[0])
+	assert.Equal([]string{"Retirement date", "2020-01-01"}, rows[1])
+
+	zr, err := zip.OpenReader(output)
+	s.Require().Nil(err)
+	defer zr.Close()
+	var charts []string
+	for _, zf := range zr.File {
+		if strings.HasPrefix(zf.Name, "xl/charts/") {
+			charts = append(charts, zf.Name)
+		}
+	}
+	assert.Equal([]string{"xl/charts/chart1.xml"}, charts)
+}
+
+func (s *rootCmdSuite) Test_Format_XLSX_DefaultOutput() {
+	assert := s.Assert()
+	file := s.file(formatPortfolio)
+
+	lines := s.run(file, "--years", "1", "--format", "xlsx")
+	name := strings.TrimSuffix(file, ".munn") + ".xlsx"
+	assert.Equal([]string{"Wrote workbook to " + name, "Final Balance:      200.00"}, lines)
+	_, err := os.Stat(name)
+	assert.Nil(err)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"github.com/Shamus03/munn"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
+
+var schemaCmd = &cobra.Command{
+	Use:          "schema",
+	Short:        "Print a JSON Schema for .munn files, for editors to autocomplete and check them",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		schema, err := munn.JSONSchema()
+		if err != nil {
+			return err
+		}
+		cmd.Println(string(schema))
+		return nil
+	},
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Shamus03/munn"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	setupValidateCmd()
+	rootCmd.AddCommand(validateCmd)
+}
+
+func setupValidateCmd() {
+	validateCmd.Flags().StringP("format", "f", "text", "Output format: text or json")
+	validateCmd.Flags().Bool("strict", false, "Fail on warnings as well as errors")
+}
+
+var validateCmd = &cobra.Command{
+	Use:          "validate FILE...",
+	Aliases:      []string{"check"},
+	Short:        "Check .munn files for errors and warnings without projecting them",
+	Args:         cobra.MinimumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		strict, _ := cmd.Flags().GetBool("strict")
+		if format != "text" && format != "json" {
+			return fmt.Errorf("invalid format: %s", format)
+		}
+
+		all := munn.Diagnostics{}
+		for _, fileName := range args {
+			_, diags := munn.ValidateFile(fileName)
+			all = append(all, diags...)
+		}
+
+		switch format {
+		case "text":
+			for _, d := range all {
+				cmd.Println(d)
+			}
+		case "json":
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(all); err != nil {
+				return err
+			}
+		}
+
+		if n := len(all.Errors()); n > 0 {
+			return fmt.Errorf("found %d errors", n)
+		}
+		if n := len(all.Warnings()); strict && n > 0 {
+			return fmt.Errorf("found %d warnings", n)
+		}
+		return nil
+	},
+}
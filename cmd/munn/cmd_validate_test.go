@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Shamus03/munn"
+	"github.com/stretchr/testify/suite"
+)
+
+type validateCmdSuite struct {
+	suite.Suite
+
+	output *strings.Builder
+}
+
+func Test_ValidateCmd(t *testing.T) {
+	suite.Run(t, &validateCmdSuite{})
+}
+
+func (s *validateCmdSuite) SetupTest() {
+	validateCmd.ResetFlags()
+	setupValidateCmd()
+
+	buf := new(strings.Builder)
+	rootCmd.SetOutput(buf)
+	s.output = buf
+}
+
+func (s *validateCmdSuite) run(args ...string) error {
+	rootCmd.SetArgs(append([]string{"validate"}, args...))
+	return rootCmd.Execute()
+}
+
+func (s *validateCmdSuite) file(contents string) string {
+	return tempFile(s.T(), "check.munn", contents)
+}
+
+const validateWarnings = `
+accounts:
+- name: Bank
+manualAdjustments:
+- account: Bank
+  time: '2020-01-01'
+  balance: 100
+transactions:
+- description: Paycheck
+  toAccount: Bank
+  amount: 100
+  schedule: Monthly(1)
+- description: Paycheck
+  toAccount: Bank
+  amount: 50
+  schedule: Once(2019-06-01)
+- description: Old job
+  toAccount: Bank
+  amount: 100
+  stop: '2019-01-01'
+  schedule: Monthly(1)
+`
+
+func (s *validateCmdSuite) Test_Example() {
+	s.Assert().Nil(s.run("example.munn"))
+	s.Assert().Equal("", s.output.String())
+}
+
+func (s *validateCmdSuite) Test_Warnings() {
+	assert := s.Assert()
+	file := s.file(validateWarnings)
+
+	assert.Nil(s.run(file))
+	assert.Equal(file+":13:16: warning: transaction 'Paycheck' has the same description as the transaction on line 9\n"+
+		file+":16:13: warning: transaction 'Paycheck' is scheduled before the first manual adjustment, so it never applies\n"+
+		file+":20:9: warning: transaction 'Old job' stops before the first manual adjustment, so it never applies\n", s.output.String())
+}
+
+func (s *validateCmdSuite) Test_Strict() {
+	s.Assert().EqualError(s.run("--strict", s.file(validateWarnings)), "found 3 warnings")
+}
+
+func (s *validateCmdSuite) Test_JSON() {
+	assert := s.Assert()
+	file := s.file(`
+accounts:
+- name: Bank
+manualAdjustments:
+- account: Bnak
+  time: '2020-01-01'
+  balance: 100
+`)
+
+	assert.EqualError(s.run("--format", "json", file, "example.munn"), "found 1 errors")
+
+	output := s.output.String()
+	var diags munn.Diagnostics
+	if assert.Nil(json.Unmarshal([]byte(output[:strings.LastIndex(output, "]")+1]), &diags)) {
+		assert.Equal(munn.Diagnostics{{
+			File:     file,
+			Line:     5,
+			Column:   12,
+			Severity: munn.SeverityError,
+			Message:  "manual adjustment: unknown account 'Bnak' (did you mean 'Bank'?)",
+		}}, diags)
+	}
+}
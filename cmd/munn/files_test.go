@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// tempFiles writes files to a temporary directory, removed when the test finishes, and returns the directory.
+// Names can have directories, which are created.
+func tempFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "munn")
+	require.Nil(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	for name, contents := range files {
+		name = filepath.Join(dir, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.Nil(t, ioutil.WriteFile(name, []byte(contents), 0644))
+	}
+	return dir
+}
+
+// tempFile writes a file to a temporary directory, removed when the test finishes, and returns its path.
+func tempFile(t *testing.T, name, contents string) string {
+	return filepath.Join(tempFiles(t, map[string]string{name: contents}), name)
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Shamus03/munn"
+)
+
+// lspServer is a language server for .munn files.
+// It only supports syncing whole documents, and handles one message at a time.
+type lspServer struct {
+	in       *bufio.Reader
+	out      io.Writer
+	docs     map[string]*lspDocument
+	shutdown bool
+}
+
+// lspDocument is a file open in the editor.
+// lastGood is the last version of the portfolio without errors, so accounts can still be completed while the file has errors.
+type lspDocument struct {
+	path      string
+	text      string
+	portfolio *munn.Portfolio
+	lastGood  *munn.Portfolio
+}
+
+type lspMessage struct {
+	ID     json.RawMessage `json:"id,omitempty"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type lspResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *lspError       `json:"error,omitempty"`
+}
+
+type lspNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type lspError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *lspError) Error() string {
+	return e.Message
+}
+
+// Codes of errors in responses
+const (
+	lspMethodNotFound = -32601
+	lspInvalidParams  = -32602
+	lspInternalError  = -32603
+)
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspTextDocumentPosition struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Position     lspPosition     `json:"position"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspCompletionItem struct {
+	Label    string      `json:"label"`
+	Kind     int         `json:"kind"`
+	Detail   string      `json:"detail,omitempty"`
+	TextEdit lspTextEdit `json:"textEdit"`
+}
+
+type lspTextEdit struct {
+	Range   lspRange `json:"range"`
+	NewText string   `json:"newText"`
+}
+
+type lspHover struct {
+	Contents lspMarkup `json:"contents"`
+}
+
+type lspMarkup struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Kinds of completion items
+const (
+	lspFunction  = 3
+	lspReference = 18
+)
+
+func newLSPServer(in io.Reader, out io.Writer) *lspServer {
+	return &lspServer{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*lspDocument),
+	}
+}
+
+// run handles messages until the client exits or closes the input.
+func (s *lspServer) run() error {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exited without shutting down")
+			}
+			return nil
+		}
+
+		result, err := s.handle(msg)
+		// Notifications don't have an id, and don't get a response
+		if msg.ID == nil {
+			continue
+		}
+		resp := lspResponse{JSONRPC: "2.0", ID: msg.ID}
+		if err != nil {
+			var lerr *lspError
+			if !errors.As(err, &lerr) {
+				lerr = &lspError{Code: lspInternalError, Message: err.Error()}
+			}
+			resp.Error = lerr
+		} else if resp.Result, err = json.Marshal(result); err != nil {
+			return err
+		}
+		if err := s.write(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// read reads a message, which is JSON after headers giving its length.
+func (s *lspServer) read() (*lspMessage, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) == 2 && strings.EqualFold(parts[0], "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %s", parts[1])
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("message missing Content-Length")
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	var msg lspMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+func (s *lspServer) write(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	return err
+}
+
+func (s *lspServer) notify(method string, params interface{}) error {
+	return s.write(lspNotification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *lspServer) handle(msg *lspMessage) (interface{}, error) {
+	switch msg.Method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":   1,
+				"completionProvider": map[string]interface{}{"triggerCharacters": []string{" ", "\"", "["}},
+				"hoverProvider":      true,
+				"definitionProvider": true,
+			},
+			"serverInfo": map[string]interface{}{"name": "munn", "version": Version},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.update(params.TextDocument.URI, params.TextDocument.Text)
+	case "textDocument/didChange":
+		var params struct {
+			TextDocument   lspTextDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			return nil, s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params struct {
+			TextDocument lspTextDocument `json:"textDocument"`
+		}
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return nil, s.publish(params.TextDocument.URI, []lspDiagnostic{})
+
+	case "textDocument/completion", "textDocument/hover", "textDocument/definition":
+		var params lspTextDocumentPosition
+		if err := decodeParams(msg, &params); err != nil {
+			return nil, err
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		switch msg.Method {
+		case "textDocument/completion":
+			return doc.completion(params.Position), nil
+		case "textDocument/hover":
+			return doc.hover(params.Position), nil
+		default:
+			return s.definition(doc, params.Position), nil
+		}
+	}
+
+	if msg.ID != nil {
+		return nil, &lspError{Code: lspMethodNotFound, Message: "method not found: " + msg.Method}
+	}
+	return nil, nil
+}
+
+func decodeParams(msg *lspMessage, v interface{}) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &lspError{Code: lspInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// update parses a document after it changes, and publishes its diagnostics.
+// Diagnostics in the files it includes are shown at the top of the document.
+func (s *lspServer) update(uri, text string) error {
+	doc, ok := s.docs[uri]
+	if !ok {
+		doc = &lspDocument{path: uriPath(uri)}
+		s.docs[uri] = doc
+	}
+	doc.text = text
+
+	p, diags := munn.ValidateSource(doc.path, strings.NewReader(text))
+	doc.portfolio = p
+	if p != nil {
+		doc.lastGood = p
+	}
+
+	lds := []lspDiagnostic{}
+	for _, d := range diags {
+		ld := lspDiagnostic{Severity: 1, Source: "munn", Message: d.Message}
+		if d.Severity == munn.SeverityWarning {
+			ld.Severity = 2
+		}
+		if d.File != "" && !samePath(d.File, doc.path) {
+			ld.Message = d.String()
+		} else if d.Line > 0 {
+			ld.Range = tokenRange(text, d.Line, d.Column)
+		}
+		lds = append(lds, ld)
+	}
+	return s.publish(uri, lds)
+}
+
+func (s *lspServer) publish(uri string, diags []lspDiagnostic) error {
+	return s.notify("textDocument/publishDiagnostics", map[string]interface{}{
+		"uri":         uri,
+		"diagnostics": diags,
+	})
+}
+
+// position converts an LSP position in the document to a position in the portfolio.
+func (d *lspDocument) position(pos lspPosition) munn.Position {
+	col := runeIndex([]rune(lineOf(d.text, pos.Line)), pos.Character)
+	return munn.Position{File: d.path, Line: pos.Line + 1, Column: col + 1}
+}
+
+var (
+	lspKeyRegex      = regexp.MustCompile(`^\s*(?:-\s+)?["']?(\w+)["']?\s*[:=]`)
+	lspListItemRegex = regexp.MustCompile(`^(\s*)-\s+`)
+	lspParentRegex   = regexp.MustCompile(`^\s*(?:-\s+)?(\w+):\s*$`)
+)
+
+var accountFields = map[string]bool{
+	"account":     true,
+	"fromAccount": true,
+	"toAccount":   true,
+	"rmdAccount":  true,
+}
+
+// completion completes account names for account references, and schedule names for schedules.
+func (d *lspDocument) completion(pos lspPosition) []lspCompletionItem {
+	key, start := completionContext(strings.Split(d.text, "\n"), pos)
+	edit := lspRange{Start: lspPosition{Line: pos.Line, Character: start}, End: pos}
+
+	items := []lspCompletionItem{}
+	switch {
+	case accountFields[key]:
+		p := d.portfolio
+		if p == nil {
+			p = d.lastGood
+		}
+		if p == nil {
+			break
+		}
+		seen := make(map[string]bool)
+		for _, acc := range p.Accounts {
+			if seen[acc.Name] {
+				continue
+			}
+			seen[acc.Name] = true
+			items = append(items, lspCompletionItem{
+				Label:    acc.Name,
+				Kind:     lspReference,
+				Detail:   acc.Group,
+				TextEdit: lspTextEdit{Range: edit, NewText: acc.Name},
+			})
+		}
+	case key == "schedule":
+		for _, name := range munn.ScheduleParserNames() {
+			items = append(items, lspCompletionItem{
+				Label:    name,
+				Kind:     lspFunction,
+				TextEdit: lspTextEdit{Range: edit, NewText: name},
+			})
+		}
+	}
+	return items
+}
+
+// completionContext finds the field of the value being typed at a position, and the character the value starts at.
+// Values in YAML lists are for the field the list is in.
+func completionContext(lines []string, pos lspPosition) (string, int) {
+	if pos.Line >= len(lines) {
+		return "", pos.Character
+	}
+	line := []rune(strings.TrimSuffix(lines[pos.Line], "\r"))
+	col := runeIndex(line, pos.Character)
+	prefix := string(line[:col])
+
+	var key, value string
+	if m := lspKeyRegex.FindStringSubmatchIndex(prefix); m != nil {
+		key, value = prefix[m[2]:m[3]], prefix[m[1]:]
+	} else if m := lspListItemRegex.FindStringSubmatchIndex(prefix); m != nil {
+		value = prefix[m[1]:]
+		indent := m[3] - m[2]
+		for i := pos.Line - 1; i >= 0; i-- {
+			l := strings.TrimSuffix(lines[i], "\r")
+			if strings.TrimSpace(l) == "" || (lspListItemRegex.MatchString(l) && len(l)-len(strings.TrimLeft(l, " ")) >= indent) {
+				continue
+			}
+			if m := lspParentRegex.FindStringSubmatch(l); m != nil {
+				key = m[1]
+			}
+			break
+		}
+	}
+
+	// The value starts after any opening quote, list bracket or comma
+	if i := strings.LastIndexAny(value, `"'[,`); i >= 0 {
+		value = value[i+1:]
+	}
+	value = strings.TrimLeft(value, " ")
+	return key, unitIndex(line, col-len([]rune(value)))
+}
+
+// hover shows a transaction's schedule and yearly cost, and when it next applies in the projection.
+func (d *lspDocument) hover(pos lspPosition) *lspHover {
+	// Projecting changes the portfolio, so use a fresh one
+	p, _ := munn.ValidateSource(d.path, strings.NewReader(d.text))
+	if p == nil {
+		return nil
+	}
+	t, ok := p.TransactionAt(d.position(pos))
+	if !ok {
+		return nil
+	}
+	p.Project(projectionYears(p, 0))
+
+	var b strings.Builder
+	name := t.Description
+	if name == "" {
+		name = "Transaction"
+	}
+	fmt.Fprintf(&b, "**%s**\n\n%.2f", name, t.Amount)
+	if s, err := munn.FormatSchedule(t.Schedule); err == nil {
+		fmt.Fprintf(&b, " %s", s)
+	}
+	if f := t.Schedule.YearlyFactor(); f > 0 {
+		fmt.Fprintf(&b, ", about %.2f a year", t.Amount*f)
+	}
+
+	var times []time.Time
+	amounts := make(map[time.Time]float32)
+	for _, e := range p.TransactionLedger(t) {
+		if _, ok := amounts[e.Time]; !ok {
+			times = append(times, e.Time)
+		}
+		amounts[e.Time] += e.Amount
+	}
+	if len(times) == 0 {
+		b.WriteString("\n\nNever applies in the projection")
+	} else {
+		b.WriteString("\n\nNext:")
+		for i, tm := range times {
+			if i == 5 {
+				break
+			}
+			fmt.Fprintf(&b, "\n- %s: %.2f", tm.Format("2006-01-02"), amounts[tm])
+		}
+	}
+	return &lspHover{Contents: lspMarkup{Kind: "markdown", Value: b.String()}}
+}
+
+// definition finds where the account referred to at a position is defined, which may be in an included file.
+func (s *lspServer) definition(doc *lspDocument, pos lspPosition) *lspLocation {
+	if doc.portfolio == nil {
+		return nil
+	}
+	acc, ok := doc.portfolio.AccountAt(doc.position(pos))
+	if !ok {
+		return nil
+	}
+	def, ok := doc.portfolio.AccountPosition(acc)
+	if !ok {
+		return nil
+	}
+
+	text := doc.text
+	if !samePath(def.File, doc.path) {
+		text = ""
+		for _, d := range s.docs {
+			if samePath(def.File, d.path) {
+				text = d.text
+			}
+		}
+		if text == "" {
+			data, err := ioutil.ReadFile(def.File)
+			if err != nil {
+				return nil
+			}
+			text = string(data)
+		}
+	}
+	return &lspLocation{URI: pathURI(def.File), Range: tokenRange(text, def.Line, def.Column)}
+}
+
+// tokenRange gets the range of the word starting at a line and column, which count characters from 1.
+func tokenRange(text string, line, col int) lspRange {
+	l := []rune(lineOf(text, line-1))
+	start := col - 1
+	if start > len(l) {
+		start = len(l)
+	}
+	end := start
+	for end < len(l) && !unicode.IsSpace(l[end]) && (end == start || !strings.ContainsRune(`:=,]}`, l[end])) {
+		end++
+	}
+	return lspRange{
+		Start: lspPosition{Line: line - 1, Character: unitIndex(l, start)},
+		End:   lspPosition{Line: line - 1, Character: unitIndex(l, end)},
+	}
+}
+
+func lineOf(text string, line int) string {
+	lines := strings.Split(text, "\n")
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[line], "\r")
+}
+
+// runeIndex converts a count of UTF-16 code units, which LSP positions count, to a count of characters in a line.
+func runeIndex(line []rune, units int) int {
+	i := 0
+	for ; i < len(line) && units > 0; i++ {
+		units -= utf16Len(line[i])
+	}
+	return i
+}
+
+// unitIndex converts a count of characters in a line to a count of UTF-16 code units.
+func unitIndex(line []rune, n int) int {
+	units := 0
+	for _, r := range line[:n] {
+		units += utf16Len(r)
+	}
+	return units
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	// Windows paths look like /C:/dir/file.munn
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+func pathURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+func samePath(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
@@ -1,5 +1,9 @@
 package main
 
+import "os"
+
 func main() {
-	rootCmd.Execute()
+	if err := rootCmd.Execute(); err != nil {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Shamus03/munn"
+)
+
+// outputFormats are the formats the projection can be printed in.
+var outputFormats = []string{"text", "csv", "tsv", "json", "ndjson", "xlsx"}
+
+// outputColumns are the columns the projection can be printed with, in their default order.
+var outputColumns = []string{"time", "account", "balance", "events"}
+
+// checkOption checks the value of a flag is one of its options.
+func checkOption(name, value string, options []string) error {
+	for _, o := range options {
+		if value == o {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid %s: %s (must be one of %s)", name, value, strings.Join(options, ", "))
+}
+
+// projectionJSON is the document printed with --format json.
+// RetirementDate is only set if there is a retirement plan and the date was found.
+type projectionJSON struct {
+	Records        []munn.ProjectionRecord `json:"records"`
+	FinalBalance   float32                 `json:"finalBalance"`
+	RetirementDate *time.Time              `json:"retirementDate"`
+	Stats          munn.PortfolioStats     `json:"stats"`
+	Warnings       munn.Diagnostics        `json:"warnings"`
+}
+
+// writeProjection prints the projection in one of the outputFormats.
+// Only the JSON format has the summaries, which are printed separately for the others.
+func writeProjection(w io.Writer, format string, columns []string, p *munn.Portfolio, recs []munn.ProjectionRecord, warnings munn.Diagnostics) error {
+	if format != "json" {
+		return writeRecords(w, format, columns, recs)
+	}
+	doc := projectionJSON{
+		Records:      recs,
+		FinalBalance: p.TotalBalance(),
+		Stats:        p.Stats(),
+		Warnings:     warnings,
+	}
+	if doc.Records == nil {
+		doc.Records = []munn.ProjectionRecord{}
+	}
+	if doc.Warnings == nil {
+		doc.Warnings = munn.Diagnostics{}
+	}
+	if p.RetirementPlan != nil {
+		if date, ok := p.RetirementPlan.RetireDate(); ok {
+			doc.RetirementDate = &date
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// writeRecords prints the projection's records.
+// Text is tab separated without a header, with each event in its own field.
+// NDJSON has a JSON record on each line, and has every column.
+func writeRecords(w io.Writer, format string, columns []string, recs []munn.ProjectionRecord) error {
+	switch format {
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		for _, r := range recs {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "text":
+		rows := make([][]string, len(recs))
+		for i, r := range recs {
+			rows[i] = recordCells(r, columns, false)
+		}
+		return writeRows(w, format, nil, rows)
+	default:
+		rows := make([][]string, len(recs))
+		for i, r := range recs {
+			rows[i] = recordCells(r, columns, true)
+		}
+		return writeRows(w, format, columns, rows)
+	}
+}
+
+// wideFormats are the formats a wide table can be printed in. Workbooks are always wide.
+var wideFormats = []string{"text", "csv", "tsv", "xlsx"}
+
+// writeWide prints a table with a row for each time, and a column for each account, then each group and the total.
+// The groups are nil if balances aren't grouped.
+func writeWide(w io.Writer, format string, accounts munn.ProjectionTable, groups *munn.ProjectionTable) error {
+	header := append([]string{"Time"}, accounts.Columns...)
+	if groups != nil {
+		header = append(header, groups.Columns...)
+	}
+	header = append(header, "Total")
+
+	rows := make([][]string, len(accounts.Times))
+	for i, t := range accounts.Times {
+		row := []string{t.Format("2006-01-02")}
+		for _, b := range accounts.Balances[i] {
+			row = append(row, fmt.Sprintf("%.2f", b))
+		}
+		if groups != nil {
+			for _, b := range groups.Balances[i] {
+				row = append(row, fmt.Sprintf("%.2f", b))
+			}
+		}
+		rows[i] = append(row, fmt.Sprintf("%.2f", accounts.Totals[i]))
+	}
+	return writeRows(w, format, header, rows)
+}
+
+// writeRows prints rows as text, CSV or TSV, after the header if there is one.
+func writeRows(w io.Writer, format string, header []string, rows [][]string) error {
+	if header != nil {
+		rows = append([][]string{header}, rows...)
+	}
+	switch format {
+	case "text":
+		for _, row := range rows {
+			if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.WriteAll(rows)
+		return cw.Error()
+	case "tsv":
+		// TSV can't quote fields, so tabs and newlines in them are replaced
+		clean := strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+		for _, row := range rows {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				cells[i] = clean.Replace(cell)
+			}
+			if _, err := fmt.Fprintln(w, strings.Join(cells, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid format: %s", format)
+	}
+}
+
+// recordCells gets a record's value for each column.
+// Events are joined into one cell, or are a cell each if there is no header to line them up with.
+func recordCells(r munn.ProjectionRecord, columns []string, joinEvents bool) []string {
+	var cells []string
+	for _, c := range columns {
+		switch c {
+		case "time":
+			cells = append(cells, r.Time.Format("2006-01-02"))
+		case "account":
+			cells = append(cells, r.AccountName)
+		case "balance":
+			cells = append(cells, fmt.Sprintf("%.2f", r.Balance))
+		case "events":
+			if joinEvents {
+				cells = append(cells, strings.Join(r.Events, "; "))
+			} else {
+				cells = append(cells, r.Events...)
+			}
+		}
+	}
+	return cells
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/Shamus03/munn"
+	"github.com/xuri/excelize/v2"
+)
+
+// xlsxStyles are the cell styles used in workbooks.
+type xlsxStyles struct {
+	header, date, currency, percent, months int
+}
+
+func newXLSXStyles(f *excelize.File) (xlsxStyles, error) {
+	var s xlsxStyles
+	date, currency, months := "yyyy-mm-dd", "$#,##0.00", "0.0"
+	for _, style := range []struct {
+		id    *int
+		style *excelize.Style
+	}{
+		{&s.header, &excelize.Style{Font: &excelize.Font{Bold: true}}},
+		{&s.date, &excelize.Style{CustomNumFmt: &date}},
+		{&s.currency, &excelize.Style{CustomNumFmt: &currency}},
+		{&s.percent, &excelize.Style{NumFmt: 10}},
+		{&s.months, &excelize.Style{CustomNumFmt: &months}},
+	} {
+		id, err := f.NewStyle(style.style)
+		if err != nil {
+			return s, err
+		}
+		*style.id = id
+	}
+	return s, nil
+}
+
+// sheetWriter writes cells to a sheet, keeping the first error so each cell doesn't need checking.
+type sheetWriter struct {
+	f     *excelize.File
+	sheet string
+	err   error
+}
+
+func (sw *sheetWriter) cell(col, row int) string {
+	name, err := excelize.CoordinatesToCellName(col, row)
+	if err != nil && sw.err == nil {
+		sw.err = err
+	}
+	return name
+}
+
+// set writes a value to a cell, with a style if it isn't 0.
+func (sw *sheetWriter) set(col, row int, v interface{}, style int) {
+	cell := sw.cell(col, row)
+	if sw.err != nil {
+		return
+	}
+	if sw.err = sw.f.SetCellValue(sw.sheet, cell, v); sw.err == nil && style != 0 {
+		sw.err = sw.f.SetCellStyle(sw.sheet, cell, cell, style)
+	}
+}
+
+// formula writes a formula to a cell, with a style if it isn't 0.
+func (sw *sheetWriter) formula(col, row int, formula string, style int) {
+	cell := sw.cell(col, row)
+	if sw.err != nil {
+		return
+	}
+	if sw.err = sw.f.SetCellFormula(sw.sheet, cell, formula); sw.err == nil && style != 0 {
+		sw.err = sw.f.SetCellStyle(sw.sheet, cell, cell, style)
+	}
+}
+
+// header writes a row of bold headings, and keeps them in view when scrolling.
+func (sw *sheetWriter) header(row int, style int, headings ...string) {
+	for i, h := range headings {
+		sw.set(i+1, row, h, style)
+	}
+	if sw.err == nil && row == 1 {
+		sw.err = sw.f.SetPanes(sw.sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	}
+}
+
+// width sets the width of columns from first to last.
+func (sw *sheetWriter) width(first, last int, width float64) {
+	if sw.err != nil || last < first {
+		return
+	}
+	start, _ := excelize.ColumnNumberToName(first)
+	end, _ := excelize.ColumnNumberToName(last)
+	sw.err = sw.f.SetColWidth(sw.sheet, start, end, width)
+}
+
+// cents rounds a balance to cents, so cells don't show float32 rounding errors.
+func cents(v float32) float64 {
+	return math.Round(float64(v)*100) / 100
+}
+
+// writeWorkbook writes an Excel workbook with sheets for the projection, the transactions, the stats and a chart of the projection.
+// The projection has a column for each account, then each group and the total, like writeWide.
+// The groups are nil if balances aren't grouped.
+func writeWorkbook(w io.Writer, p *munn.Portfolio, accounts munn.ProjectionTable, groups *munn.ProjectionTable) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newXLSXStyles(f)
+	if err != nil {
+		return err
+	}
+	if err := f.SetSheetName("Sheet1", "Projection"); err != nil {
+		return err
+	}
+	for _, sheet := range []string{"Transactions", "Stats", "Chart"} {
+		if _, err := f.NewSheet(sheet); err != nil {
+			return err
+		}
+	}
+
+	columns, err := writeProjectionSheet(&sheetWriter{f: f, sheet: "Projection"}, styles, accounts, groups)
+	if err != nil {
+		return err
+	}
+	if err := writeTransactionsSheet(&sheetWriter{f: f, sheet: "Transactions"}, styles, p); err != nil {
+		return err
+	}
+	if err := writeStatsSheet(&sheetWriter{f: f, sheet: "Stats"}, styles, p); err != nil {
+		return err
+	}
+	if len(accounts.Times) > 0 {
+		if err := addProjectionChart(f, columns, len(accounts.Times)); err != nil {
+			return err
+		}
+	}
+	return f.Write(w)
+}
+
+// writeProjectionSheet writes the projection, with a formula for each row's total, and returns the number of columns.
+func writeProjectionSheet(sw *sheetWriter, styles xlsxStyles, accounts munn.ProjectionTable, groups *munn.ProjectionTable) (int, error) {
+	header := append([]string{"Time"}, accounts.Columns...)
+	if groups != nil {
+		header = append(header, groups.Columns...)
+	}
+	header = append(header, "Total")
+	sw.header(1, styles.header, header...)
+
+	for i, t := range accounts.Times {
+		row := i + 2
+		sw.set(1, row, t, styles.date)
+		col := 2
+		for _, b := range accounts.Balances[i] {
+			sw.set(col, row, cents(b), styles.currency)
+			col++
+		}
+		if groups != nil {
+			for _, b := range groups.Balances[i] {
+				sw.set(col, row, cents(b), styles.currency)
+				col++
+			}
+		}
+		// Groups are made of the same accounts, so only the accounts are added up
+		sw.formula(col, row, fmt.Sprintf("SUM(%s:%s)", sw.cell(2, row), sw.cell(len(accounts.Columns)+1, row)), styles.currency)
+	}
+
+	sw.width(1, 1, 12)
+	sw.width(2, len(header), 16)
+	return len(header), sw.err
+}
+
+// writeTransactionsSheet writes the portfolio's transactions, with their schedules and about how much they move each year.
+func writeTransactionsSheet(sw *sheetWriter, styles xlsxStyles, p *munn.Portfolio) error {
+	sw.header(1, styles.header, "Description", "Category", "From", "To", "Amount", "Schedule", "Per year", "Start", "Stop")
+	for i, t := range p.Transactions {
+		row := i + 2
+		var from []string
+		for _, a := range t.FromAccounts {
+			from = append(from, a.Name)
+		}
+		var to string
+		if t.ToAccount != nil {
+			to = t.ToAccount.Name
+		}
+		schedule, _ := munn.FormatSchedule(t.Schedule)
+
+		sw.set(1, row, t.Description, 0)
+		sw.set(2, row, t.Category, 0)
+		sw.set(3, row, strings.Join(from, ", "), 0)
+		sw.set(4, row, to, 0)
+		sw.set(5, row, cents(t.Amount), styles.currency)
+		sw.set(6, row, schedule, 0)
+		if f := t.Schedule.YearlyFactor(); f > 0 {
+			sw.set(7, row, cents(t.Amount*f), styles.currency)
+		}
+		if t.Start != nil {
+			sw.set(8, row, *t.Start, styles.date)
+		}
+		if t.Stop != nil {
+			sw.set(9, row, *t.Stop, styles.date)
+		}
+	}
+
+	sw.width(1, 1, 30)
+	sw.width(2, 9, 14)
+	return sw.err
+}
+
+// writeStatsSheet writes the final balance, the retirement date if there is a plan, and the portfolio's stats.
+func writeStatsSheet(sw *sheetWriter, styles xlsxStyles, p *munn.Portfolio) error {
+	stats := p.Stats()
+	row := 1
+	stat := func(name string, v interface{}, style int) {
+		sw.set(1, row, name, styles.header)
+		sw.set(2, row, v, style)
+		row++
+	}
+
+	stat("Final balance", cents(p.TotalBalance()), styles.currency)
+	if p.RetirementPlan != nil {
+		if date, ok := p.RetirementPlan.RetireDate(); ok {
+			stat("Retirement date", date, styles.date)
+		} else {
+			stat("Retirement date", "could not find", 0)
+		}
+	}
+	stat("Average monthly expenses", cents(stats.AverageMonthlyExpenses), styles.currency)
+	stat("Average monthly income", cents(stats.AverageMonthlyIncome), styles.currency)
+	stat("Average monthly growth", cents(stats.AverageMonthlyGrowth), styles.currency)
+	stat("Savings rate", float64(stats.SavingsRate), styles.percent)
+	stat("Average monthly burn", cents(stats.MonthlyBurn), styles.currency)
+	stat("Cash", cents(stats.Cash), styles.currency)
+	if math.IsInf(float64(stats.RunwayMonths), 1) {
+		stat("Runway (months)", "indefinite", 0)
+	} else {
+		stat("Runway (months)", float64(stats.RunwayMonths), styles.months)
+	}
+
+	// Each account's monthly flow is a table below the stats
+	row++
+	sw.header(row, styles.header, "Account", "Income", "Expenses", "Transfers in", "Transfers out", "Net")
+	for _, a := range stats.Accounts {
+		row++
+		sw.set(1, row, a.Account, 0)
+		for i, v := range []float32{a.Income, a.Expenses, a.TransfersIn, a.TransfersOut, a.Net} {
+			sw.set(i+2, row, cents(v), styles.currency)
+		}
+	}
+
+	sw.width(1, 1, 26)
+	sw.width(2, 6, 14)
+	return sw.err
+}
+
+// addProjectionChart adds a line chart to the Chart sheet, with a line for each column of the Projection sheet after the time.
+func addProjectionChart(f *excelize.File, columns, rows int) error {
+	var series []excelize.ChartSeries
+	for col := 2; col <= columns; col++ {
+		name, err := excelize.ColumnNumberToName(col)
+		if err != nil {
+			return err
+		}
+		series = append(series, excelize.ChartSeries{
+			Name:       fmt.Sprintf("Projection!$%s$1", name),
+			Categories: fmt.Sprintf("Projection!$A$2:$A$%d", rows+1),
+			Values:     fmt.Sprintf("Projection!$%s$2:$%s$%d", name, name, rows+1),
+		})
+	}
+	return f.AddChart("Chart", "A1", &excelize.Chart{
+		Type:      excelize.Line,
+		Series:    series,
+		Title:     []excelize.RichTextRun{{Text: "Projection"}},
+		Legend:    excelize.ChartLegend{Position: "bottom"},
+		Dimension: excelize.ChartDimension{Width: 960, Height: 540},
+	})
+}
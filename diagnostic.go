@@ -0,0 +1,354 @@
+package munn
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity is how serious a diagnostic is.
+type Severity int
+
+const (
+	// SeverityError is a problem which stops the portfolio from being used.
+	SeverityError Severity = iota
+	// SeverityWarning is something questionable which is probably a mistake.
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// MarshalText encodes the severity as its name.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes the severity from its name.
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "error":
+		*s = SeverityError
+	case "warning":
+		*s = SeverityWarning
+	default:
+		return fmt.Errorf("invalid severity: %s", text)
+	}
+	return nil
+}
+
+// Diagnostic is an error or a warning found in a portfolio file.
+// Line and Column are 0 if the diagnostic isn't about a specific part of the file.
+type Diagnostic struct {
+	File     string   `json:"file,omitempty"`
+	Line     int      `json:"line,omitempty"`
+	Column   int      `json:"column,omitempty"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	var pos string
+	if d.File != "" {
+		pos = d.File + ":"
+	}
+	if d.Line > 0 {
+		pos += fmt.Sprintf("%d:%d:", d.Line, d.Column)
+	}
+	if pos != "" {
+		pos += " "
+	}
+	return fmt.Sprintf("%s%s: %s", pos, d.Severity, d.Message)
+}
+
+// Diagnostics is a list of diagnostics, and is the error returned by Parse.
+type Diagnostics []Diagnostic
+
+func (ds Diagnostics) Error() string {
+	lines := make([]string, len(ds))
+	for i, d := range ds {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Errors gets the diagnostics which are errors.
+func (ds Diagnostics) Errors() Diagnostics {
+	return ds.filter(SeverityError)
+}
+
+// Warnings gets the diagnostics which are warnings.
+func (ds Diagnostics) Warnings() Diagnostics {
+	return ds.filter(SeverityWarning)
+}
+
+func (ds Diagnostics) filter(s Severity) Diagnostics {
+	var filtered Diagnostics
+	for _, d := range ds {
+		if d.Severity == s {
+			filtered = append(filtered, d)
+		}
+	}
+	return filtered
+}
+
+// WithFile gets a copy of the diagnostics for the named file, for those which aren't already for a file.
+func (ds Diagnostics) WithFile(name string) Diagnostics {
+	withFile := make(Diagnostics, len(ds))
+	for i, d := range ds {
+		if d.File == "" {
+			d.File = name
+		}
+		withFile[i] = d
+	}
+	return withFile
+}
+
+// parser collects diagnostics while parsing a portfolio.
+// doc and file are the document and file currently being read, and syntax is the syntax of the main file.
+type parser struct {
+	doc       *yaml.Node
+	file      string
+	syntax    syntax
+	nodeFiles map[*yaml.Node]string
+	files     []string
+	including []string
+	included  map[string]bool
+	vars      *yaml.Node
+	varValues map[string]exprValue
+	varFailed map[string]bool
+	varStack  []string
+	diags     Diagnostics
+}
+
+func (ps *parser) errorf(n *yaml.Node, format string, args ...interface{}) {
+	ps.add(n, SeverityError, fmt.Sprintf(format, args...))
+}
+
+func (ps *parser) warnf(n *yaml.Node, format string, args ...interface{}) {
+	ps.add(n, SeverityWarning, fmt.Sprintf(format, args...))
+}
+
+func (ps *parser) add(n *yaml.Node, s Severity, msg string) {
+	d := Diagnostic{
+		File:     ps.file,
+		Severity: s,
+		Message:  msg,
+	}
+	if n != nil {
+		d.File = ps.nodeFiles[n]
+		d.Line = n.Line
+		d.Column = n.Column
+	}
+	ps.diags = append(ps.diags, d)
+}
+
+// sort orders the diagnostics by where they are, with files in the order they were read.
+func (ps *parser) sort() {
+	order := make(map[string]int)
+	for i, f := range ps.files {
+		if _, ok := order[f]; !ok {
+			order[f] = i
+		}
+	}
+	ds := ps.diags
+	sort.SliceStable(ds, func(i, j int) bool {
+		if order[ds[i].File] != order[ds[j].File] {
+			return order[ds[i].File] < order[ds[j].File]
+		}
+		if ds[i].Line != ds[j].Line {
+			return ds[i].Line < ds[j].Line
+		}
+		return ds[i].Column < ds[j].Column
+	})
+}
+
+// where describes where a node is, for a diagnostic about another node.
+func (ps *parser) where(n, from *yaml.Node) string {
+	if ps.nodeFiles[n] == ps.nodeFiles[from] {
+		return fmt.Sprintf("on line %d", n.Line)
+	}
+	return fmt.Sprintf("at %s:%d:%d", ps.nodeFiles[n], n.Line, n.Column)
+}
+
+// at finds the node at a path of mapping keys and sequence indexes in the document.
+// If the path doesn't exist (such as a missing field), the closest node that does is used.
+func (ps *parser) at(path ...interface{}) *yaml.Node {
+	n := ps.doc
+	for _, p := range path {
+		var next *yaml.Node
+		switch p := p.(type) {
+		case string:
+			if n.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					if n.Content[i].Value == p {
+						next = n.Content[i+1]
+						break
+					}
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && p < len(n.Content) {
+				next = n.Content[p]
+			}
+		}
+		if next == nil {
+			return n
+		}
+		if next.Kind == yaml.AliasNode {
+			next = next.Alias
+		}
+		n = next
+	}
+	return n
+}
+
+// nodeError is an error decoding a node, which yaml.v3 collects with the other decoding errors.
+func nodeError(n *yaml.Node, format string, args ...interface{}) error {
+	return &yaml.TypeError{Errors: []string{fmt.Sprintf("line %d:%d: ", n.Line, n.Column) + fmt.Sprintf(format, args...)}}
+}
+
+var yamlErrorRegex = regexp.MustCompile(`^(?:yaml: )?line (\d+)(?::(\d+))?: (.*)$`)
+
+// yamlError adds diagnostics for the errors from yaml.v3, which only have line numbers unless they came from nodeError.
+func (ps *parser) yamlError(err error) {
+	msgs := []string{err.Error()}
+	if e, ok := err.(*yaml.TypeError); ok {
+		msgs = e.Errors
+	}
+	for _, msg := range msgs {
+		m := yamlErrorRegex.FindStringSubmatch(msg)
+		if m == nil {
+			ps.errorf(nil, "%s", strings.TrimPrefix(msg, "yaml: "))
+			continue
+		}
+		d := Diagnostic{
+			File:     ps.file,
+			Severity: SeverityError,
+			Message:  m[3],
+		}
+		d.Line, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			d.Column, _ = strconv.Atoi(m[2])
+		} else {
+			d.Column = ps.column(d.Line)
+		}
+		ps.diags = append(ps.diags, d)
+	}
+}
+
+// column guesses the column of an error on a line, as the first value on it.
+func (ps *parser) column(line int) int {
+	col := 1
+	var walk func(n *yaml.Node) bool
+	walk = func(n *yaml.Node) bool {
+		for i, c := range n.Content {
+			if n.Kind == yaml.MappingNode && i%2 == 0 {
+				continue
+			}
+			if c.Line == line {
+				col = c.Column
+				return true
+			}
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	if ps.doc != nil {
+		walk(ps.doc)
+	}
+	return col
+}
+
+// yamlName gets the name of a struct field in YAML.
+func yamlName(f reflect.StructField) string {
+	if name := strings.Split(f.Tag.Get("yaml"), ",")[0]; name != "" {
+		return name
+	}
+	return strings.ToLower(f.Name)
+}
+
+// fieldByYAMLName finds the struct field with a name in YAML.
+func fieldByYAMLName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.PkgPath == "" && yamlName(f) == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+var unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// checkFields adds errors for keys in the document which aren't fields in the spec, suggesting the closest field.
+func (ps *parser) checkFields(n *yaml.Node, t reflect.Type) {
+	// Anchored nodes are checked where they are defined
+	if n.Kind == yaml.AliasNode {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if reflect.PtrTo(t).Implements(unmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		fields := make(map[string]reflect.Type)
+		var names []string
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := yamlName(f)
+			fields[name] = f.Type
+			names = append(names, name)
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			if k.Value == "<<" {
+				continue
+			}
+			ft, ok := fields[k.Value]
+			if !ok {
+				if s, ok := closest(k.Value, names); ok {
+					ps.errorf(k, "unknown field '%s' (did you mean '%s'?)", k.Value, s)
+				} else {
+					ps.errorf(k, "unknown field '%s'", k.Value)
+				}
+				continue
+			}
+			ps.checkFields(v, ft)
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, c := range n.Content {
+			ps.checkFields(c, t.Elem())
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 1; i < len(n.Content); i += 2 {
+			ps.checkFields(n.Content[i], t.Elem())
+		}
+	}
+}
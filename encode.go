@@ -0,0 +1,279 @@
+package munn
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Marshal encodes a portfolio in the .munn format, so parsing it gives an equivalent portfolio.
+// Included files and variables aren't kept by parsing, so everything is written to a single file with expressions replaced by their values.
+// Transactions added by vesting grants and employer matches are written as their grant or match, and the retirement plan isn't written.
+func Marshal(p *Portfolio) ([]byte, error) {
+	var b bytes.Buffer
+	if err := Encode(&b, p); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Encode writes a portfolio to w in the .munn format, the same as Marshal.
+func Encode(w io.Writer, p *Portfolio) error {
+	spec, err := newPortfolioSpec(p)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(spec); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func newPortfolioSpec(p *Portfolio) (*portfolioSpec, error) {
+	spec := &portfolioSpec{
+		YearsToProject: p.YearsToProject,
+	}
+
+	if p.RMDStartAge != 0 {
+		age := p.RMDStartAge
+		spec.RMD.StartAge = &age
+	}
+	// The table is the uniform lifetime table with the file's periods added, so only the changes need to be written
+	for age, period := range p.RMDTable {
+		if uniform, ok := UniformLifetimeTable[age]; !ok || uniform != period {
+			if spec.RMD.Table == nil {
+				spec.RMD.Table = make(map[int]float32)
+			}
+			spec.RMD.Table[age] = period
+		}
+	}
+
+	refs, ids := accountRefsFor(p.Accounts)
+	for _, a := range p.Accounts {
+		accSpec := accountSpec{
+			Name:               a.Name,
+			Group:              a.Group,
+			Tags:               a.Tags,
+			AnnualInterestRate: a.AnnualInterestRate,
+			ContributionLimit:  a.AnnualContributionLimit,
+			LimitGrowth:        a.ContributionLimitGrowth,
+			OwnerBirthDate:     (*laxTime)(a.OwnerBirthDate),
+		}
+		if ids[a] {
+			accSpec.ID = refs[a]
+		}
+		if a.TaxTreatment != Taxable {
+			accSpec.TaxTreatment = a.TaxTreatment.String()
+		}
+		if a.CostBasisMethod != NoCostBasis {
+			accSpec.CostBasis = a.CostBasisMethod.String()
+		}
+		if a.RMDAccount != nil {
+			accSpec.RMDAccount = refs[a.RMDAccount]
+		}
+		spec.Accounts = append(spec.Accounts, accSpec)
+	}
+
+	for _, m := range p.ManualAdjustments {
+		balance := m.Balance
+		spec.ManualAdjustments = append(spec.ManualAdjustments, manualAdjustmentSpec{
+			Account: refs[m.Account],
+			Time:    laxTime(m.Time),
+			Balance: &balance,
+			Basis:   m.Basis,
+		})
+	}
+
+	matches := make(map[*Transaction]*Transaction)
+	for _, t := range p.Transactions {
+		if t.MatchOf != nil {
+			matches[t.MatchOf] = t
+		}
+	}
+	for _, t := range p.Transactions {
+		if t.MatchOf != nil || t.grant != nil {
+			continue
+		}
+		if _, err := FormatSchedule(t.Schedule); err != nil {
+			return nil, fmt.Errorf("transaction '%s': %v", t.Description, err)
+		}
+		amount := t.Amount
+		trans := transactionSpec{
+			Description: t.Description,
+			Category:    t.Category,
+			SubCategory: t.SubCategory,
+			Growth:      t.AnnualGrowth,
+			Schedule:    jsonSchedule{t.Schedule},
+			Start:       (*laxTime)(t.Start),
+			Stop:        (*laxTime)(t.Stop),
+			Taxable:     t.Taxable,
+			Deductible:  t.Deductible,
+		}
+		if t.Salary != nil {
+			trans.Salary = t.Salary.Description
+		}
+		if t.Salary != nil && t.SalaryRate != 0 {
+			rate := t.SalaryRate
+			trans.SalaryRate = &rate
+		} else {
+			trans.Amount = &amount
+		}
+		for _, a := range t.FromAccounts {
+			trans.FromAccount = append(trans.FromAccount, refs[a])
+		}
+		if t.ToAccount != nil {
+			trans.ToAccount = refs[t.ToAccount]
+		}
+		if m, ok := matches[t]; ok {
+			trans.Match = &matchSpec{
+				Rate: m.MatchRate,
+				UpTo: m.MatchLimit,
+			}
+		}
+		spec.Transactions = append(spec.Transactions, trans)
+	}
+
+	for _, pen := range p.Pensions {
+		benefit := pen.Benefit
+		penSpec := pensionSpec{
+			ToAccount:   refs[pen.ToAccount],
+			Description: pen.Description,
+			Benefit:     &benefit,
+			BirthDate:   newLaxTime(pen.BirthDate),
+			ClaimDate:   newLaxTime(pen.ClaimDate),
+			COLA:        pen.COLA,
+			Taxable:     pen.Taxable,
+		}
+		if pen.FullRetirementAge != DefaultFullRetirementAge {
+			age := pen.FullRetirementAge
+			penSpec.FullRetirementAge = &age
+		}
+		spec.Pensions = append(spec.Pensions, penSpec)
+	}
+
+	for _, g := range p.VestingGrants {
+		// Grants don't keep their account, so find it from the grant's vests
+		var to *Account
+		for _, t := range p.Transactions {
+			if t.grant == g {
+				to = t.ToAccount
+				break
+			}
+		}
+		if to == nil {
+			return nil, fmt.Errorf("grant '%s' has no vests", g.Description)
+		}
+		shares, price := g.Shares, g.Price
+		grant := grantSpec{
+			ToAccount:   refs[to],
+			Description: g.Description,
+			Shares:      &shares,
+			Price:       &price,
+			PriceGrowth: g.PriceGrowth,
+			Start:       newLaxTime(g.Start),
+			Withholding: g.Withholding,
+			Taxable:     g.Taxable,
+		}
+		grant.CliffMonths = nonDefault(g.CliffMonths, 12)
+		grant.VestingMonths = nonDefault(g.VestingMonths, 48)
+		grant.IntervalMonths = nonDefault(g.IntervalMonths, 3)
+		spec.Grants = append(spec.Grants, grant)
+	}
+
+	for _, r := range p.Rebalances {
+		if _, err := FormatSchedule(r.Schedule); err != nil {
+			return nil, fmt.Errorf("rebalance '%s': %v", r.Description, err)
+		}
+		rebalance := rebalanceSpec{
+			Description: r.Description,
+			Schedule:    jsonSchedule{r.Schedule},
+			Tolerance:   r.Tolerance,
+		}
+		for _, t := range r.Targets {
+			rebalance.Targets = append(rebalance.Targets, rebalanceTargetSpec{
+				Account: refs[t.Account],
+				Percent: t.Percent,
+			})
+		}
+		spec.Rebalances = append(spec.Rebalances, rebalance)
+	}
+
+	if p.Taxes != nil {
+		spec.Taxes = &taxesSpec{
+			Inflation: p.Taxes.Inflation,
+		}
+		if p.Taxes.Account != nil {
+			spec.Taxes.Account = refs[p.Taxes.Account]
+		}
+		for _, table := range p.Taxes.Tables {
+			year := taxYearSpec{
+				Year:              table.Year,
+				StandardDeduction: table.StandardDeduction,
+			}
+			for _, b := range table.Brackets {
+				year.Brackets = append(year.Brackets, taxBracketSpec{
+					Over: b.Over,
+					Rate: b.Rate,
+				})
+			}
+			spec.Taxes.Years = append(spec.Taxes.Years, year)
+		}
+	}
+
+	return spec, nil
+}
+
+// accountRefsFor gets how to refer to each account: by its name, or by an id if the name doesn't refer to only that account.
+// Ids are the account's position in the portfolio, starting from 1, and ids reports which accounts need one.
+func accountRefsFor(accs []*Account) (refs map[*Account]accountRef, ids map[*Account]bool) {
+	names := make(map[string]int)
+	for _, a := range accs {
+		names[a.Name]++
+	}
+
+	refs = make(map[*Account]accountRef)
+	ids = make(map[*Account]bool)
+	used := make(map[accountRef]bool)
+	for i, a := range accs {
+		if names[a.Name] > 1 || a.Name == "" {
+			refs[a], ids[a] = accountRef(strconv.Itoa(i+1)), true
+			used[refs[a]] = true
+		}
+	}
+	// Ids take precedence over names, so a name which is also an id needs an id of its own
+	for changed := true; changed; {
+		changed = false
+		for i, a := range accs {
+			if !ids[a] && used[accountRef(a.Name)] {
+				refs[a], ids[a] = accountRef(strconv.Itoa(i+1)), true
+				used[refs[a]] = true
+				changed = true
+			}
+		}
+	}
+	for _, a := range accs {
+		if !ids[a] {
+			refs[a] = accountRef(a.Name)
+		}
+	}
+	return refs, ids
+}
+
+func newLaxTime(t time.Time) *laxTime {
+	l := laxTime(t)
+	return &l
+}
+
+// nonDefault gets a pointer to the value, or nil if it is the default.
+func nonDefault(v, def int) *int {
+	if v == def {
+		return nil
+	}
+	return &v
+}
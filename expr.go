@@ -0,0 +1,370 @@
+package munn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type exprKind int
+
+const (
+	exprNumber exprKind = iota
+	exprDate
+	exprDuration
+)
+
+func (k exprKind) String() string {
+	switch k {
+	case exprDate:
+		return "date"
+	case exprDuration:
+		return "duration"
+	default:
+		return "number"
+	}
+}
+
+// exprValue is the value of an expression: a number, a date, or a duration of months and days.
+type exprValue struct {
+	kind   exprKind
+	num    float64
+	date   time.Time
+	months int
+	days   int
+}
+
+var (
+	exprDateRegex     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+	exprNumberRegex   = regexp.MustCompile(`^\d+(\.\d+)?`)
+	exprDurationRegex = regexp.MustCompile(`^(\d+)([ymwd])\b`)
+	exprNameRegex     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+)
+
+// evalExpr evaluates an expression of numbers, dates (2006-01-02), durations (such as 5y, 6m, 2w or 10d) and variables,
+// with +, -, *, / and parentheses.
+func evalExpr(s string, lookup func(name string) (exprValue, error)) (exprValue, error) {
+	e := &exprParser{s: s, lookup: lookup}
+	v, err := e.sum()
+	if err != nil {
+		return exprValue{}, err
+	}
+	if e.skipSpace(); e.pos < len(e.s) {
+		return exprValue{}, fmt.Errorf("unexpected '%s' in expression", e.s[e.pos:])
+	}
+	return v, nil
+}
+
+type exprParser struct {
+	s      string
+	pos    int
+	lookup func(name string) (exprValue, error)
+}
+
+func (e *exprParser) skipSpace() {
+	for e.pos < len(e.s) && (e.s[e.pos] == ' ' || e.s[e.pos] == '\t') {
+		e.pos++
+	}
+}
+
+func (e *exprParser) next(ops string) (byte, bool) {
+	e.skipSpace()
+	if e.pos < len(e.s) {
+		for i := 0; i < len(ops); i++ {
+			if e.s[e.pos] == ops[i] {
+				e.pos++
+				return ops[i], true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (e *exprParser) sum() (exprValue, error) {
+	v, err := e.product()
+	if err != nil {
+		return v, err
+	}
+	for {
+		op, ok := e.next("+-")
+		if !ok {
+			return v, nil
+		}
+		w, err := e.product()
+		if err != nil {
+			return v, err
+		}
+		if v, err = apply(op, v, w); err != nil {
+			return v, err
+		}
+	}
+}
+
+func (e *exprParser) product() (exprValue, error) {
+	v, err := e.unary()
+	if err != nil {
+		return v, err
+	}
+	for {
+		op, ok := e.next("*/")
+		if !ok {
+			return v, nil
+		}
+		w, err := e.unary()
+		if err != nil {
+			return v, err
+		}
+		if v, err = apply(op, v, w); err != nil {
+			return v, err
+		}
+	}
+}
+
+func (e *exprParser) unary() (exprValue, error) {
+	if _, ok := e.next("-"); ok {
+		v, err := e.unary()
+		if err != nil {
+			return v, err
+		}
+		return apply('*', exprValue{kind: exprNumber, num: -1}, v)
+	}
+	return e.term()
+}
+
+func (e *exprParser) term() (exprValue, error) {
+	e.skipSpace()
+	rest := e.s[e.pos:]
+	if rest == "" {
+		return exprValue{}, fmt.Errorf("unexpected end of expression")
+	}
+
+	if _, ok := e.next("("); ok {
+		v, err := e.sum()
+		if err != nil {
+			return v, err
+		}
+		if _, ok := e.next(")"); !ok {
+			return v, fmt.Errorf("missing ')' in expression")
+		}
+		return v, nil
+	}
+
+	if m := exprDateRegex.FindString(rest); m != "" {
+		e.pos += len(m)
+		t, err := time.Parse("2006-01-02", m)
+		if err != nil {
+			return exprValue{}, err
+		}
+		return exprValue{kind: exprDate, date: t}, nil
+	}
+	if m := exprDurationRegex.FindStringSubmatch(rest); m != nil {
+		e.pos += len(m[0])
+		n, _ := strconv.Atoi(m[1])
+		switch m[2] {
+		case "y":
+			return exprValue{kind: exprDuration, months: n * 12}, nil
+		case "m":
+			return exprValue{kind: exprDuration, months: n}, nil
+		case "w":
+			return exprValue{kind: exprDuration, days: n * 7}, nil
+		default:
+			return exprValue{kind: exprDuration, days: n}, nil
+		}
+	}
+	if m := exprNumberRegex.FindString(rest); m != "" {
+		e.pos += len(m)
+		n, err := strconv.ParseFloat(m, 64)
+		return exprValue{kind: exprNumber, num: n}, err
+	}
+	if m := exprNameRegex.FindString(rest); m != "" {
+		e.pos += len(m)
+		return e.lookup(m)
+	}
+	return exprValue{}, fmt.Errorf("unexpected '%s' in expression", rest)
+}
+
+// apply applies an operator to two values, if it makes sense for their kinds.
+func apply(op byte, a, b exprValue) (exprValue, error) {
+	switch {
+	case a.kind == exprNumber && b.kind == exprNumber:
+		switch op {
+		case '+':
+			return exprValue{kind: exprNumber, num: a.num + b.num}, nil
+		case '-':
+			return exprValue{kind: exprNumber, num: a.num - b.num}, nil
+		case '*':
+			return exprValue{kind: exprNumber, num: a.num * b.num}, nil
+		case '/':
+			if b.num == 0 {
+				return exprValue{}, fmt.Errorf("division by zero")
+			}
+			return exprValue{kind: exprNumber, num: a.num / b.num}, nil
+		}
+	case a.kind == exprDate && b.kind == exprDuration && (op == '+' || op == '-'):
+		if op == '-' {
+			b.months, b.days = -b.months, -b.days
+		}
+		return exprValue{kind: exprDate, date: a.date.AddDate(0, b.months, b.days)}, nil
+	case a.kind == exprDuration && b.kind == exprDate && op == '+':
+		return apply(op, b, a)
+	case a.kind == exprDuration && b.kind == exprDuration && (op == '+' || op == '-'):
+		if op == '-' {
+			b.months, b.days = -b.months, -b.days
+		}
+		return exprValue{kind: exprDuration, months: a.months + b.months, days: a.days + b.days}, nil
+	case a.kind == exprDuration && b.kind == exprNumber && op == '*':
+		return exprValue{kind: exprDuration, months: int(math.Round(float64(a.months) * b.num)), days: int(math.Round(float64(a.days) * b.num))}, nil
+	case a.kind == exprNumber && b.kind == exprDuration && op == '*':
+		return apply(op, b, a)
+	}
+	return exprValue{}, fmt.Errorf("cannot use %c with a %s and a %s", op, a.kind, b.kind)
+}
+
+// errReported is returned when evaluating an invalid variable, whose error has already been reported where it is defined.
+var errReported = errors.New("error already reported")
+
+// lookupVar gets the value of a variable from the main file's vars, evaluating it the first time it is used.
+func (ps *parser) lookupVar(name string) (exprValue, error) {
+	if v, ok := ps.varValues[name]; ok {
+		return v, nil
+	}
+	if ps.varFailed[name] {
+		return exprValue{}, errReported
+	}
+
+	n := ps.varNode(name)
+	if n == nil {
+		var names []string
+		if ps.vars != nil {
+			for i := 0; i+1 < len(ps.vars.Content); i += 2 {
+				names = append(names, ps.vars.Content[i].Value)
+			}
+		}
+		if s, ok := closest(name, names); ok {
+			return exprValue{}, fmt.Errorf("undefined variable '%s' (did you mean '%s'?)", name, s)
+		}
+		return exprValue{}, fmt.Errorf("undefined variable '%s'", name)
+	}
+	for i, v := range ps.varStack {
+		if v == name {
+			return exprValue{}, fmt.Errorf("variable cycle: %s", strings.Join(append(ps.varStack[i:], name), " -> "))
+		}
+	}
+
+	ps.varStack = append(ps.varStack, name)
+	v, err := ps.varValue(n)
+	ps.varStack = ps.varStack[:len(ps.varStack)-1]
+	if err != nil {
+		if err != errReported {
+			ps.errorf(n, "variable '%s': %v", name, err)
+		}
+		ps.varFailed[name] = true
+		return exprValue{}, errReported
+	}
+	ps.varValues[name] = v
+	return v, nil
+}
+
+func (ps *parser) varNode(name string) *yaml.Node {
+	if ps.vars == nil {
+		return nil
+	}
+	for i := 0; i+1 < len(ps.vars.Content); i += 2 {
+		if ps.vars.Content[i].Value == name {
+			return ps.vars.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// varValue gets the value of a variable's node: a number, or a string with a date, a duration or an expression.
+func (ps *parser) varValue(n *yaml.Node) (exprValue, error) {
+	if n.Kind != yaml.ScalarNode {
+		return exprValue{}, fmt.Errorf("must be a number, a date, a duration or an expression")
+	}
+	if n.Tag == "!!int" || n.Tag == "!!float" {
+		num, err := strconv.ParseFloat(n.Value, 64)
+		return exprValue{kind: exprNumber, num: num}, err
+	}
+	return evalExpr(strings.TrimPrefix(n.Value, "="), ps.lookupVar)
+}
+
+var laxTimeType = reflect.TypeOf(laxTime{})
+
+// evalExprs replaces expressions (strings starting with =) in number and date fields with their values.
+func (ps *parser) evalExprs(n *yaml.Node, t reflect.Type) {
+	if n.Kind == yaml.AliasNode {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if t == laxTimeType {
+			ps.evalExpr(n, exprDate)
+			return
+		}
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if f, ok := fieldByYAMLName(t, n.Content[i].Value); ok {
+				ps.evalExprs(n.Content[i+1], f.Type)
+			}
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, c := range n.Content {
+			ps.evalExprs(c, t.Elem())
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 1; i < len(n.Content); i += 2 {
+			ps.evalExprs(n.Content[i], t.Elem())
+		}
+	case reflect.Int, reflect.Float32, reflect.Float64:
+		ps.evalExpr(n, exprNumber)
+	}
+}
+
+// evalExpr replaces an expression node with its value, which must be of the given kind.
+func (ps *parser) evalExpr(n *yaml.Node, kind exprKind) {
+	if n.Kind != yaml.ScalarNode || n.Tag != "!!str" || !strings.HasPrefix(n.Value, "=") {
+		return
+	}
+	v, err := evalExpr(n.Value[1:], ps.lookupVar)
+	if err == nil && v.kind != kind {
+		err = fmt.Errorf("expected a %s, but the expression is a %s", kind, v.kind)
+	}
+	if err != nil {
+		if err != errReported {
+			ps.errorf(n, "%v", err)
+		}
+		// Clear the node, so decoding it doesn't add another error
+		n.Tag, n.Value = "!!null", ""
+		return
+	}
+
+	n.Style = 0
+	switch {
+	case kind == exprDate:
+		n.Value = v.date.Format("2006-01-02")
+	case v.num == math.Trunc(v.num):
+		n.Tag, n.Value = "!!int", strconv.FormatFloat(v.num, 'f', -1, 64)
+	default:
+		n.Tag, n.Value = "!!float", strconv.FormatFloat(v.num, 'f', -1, 64)
+	}
+}
@@ -0,0 +1,369 @@
+package munn
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var jsonScheduleType = reflect.TypeOf(jsonSchedule{})
+
+// blockScalarRegex matches a line ending in a literal or folded block scalar's header, such as `description: |`.
+var blockScalarRegex = regexp.MustCompile(`(^|[:-] )[|>][-+0-9]*( +#.*)?$`)
+
+// Format rewrites a .munn file in a canonical layout, keeping its comments and anchors.
+// Fields are always in the same order, accounts are sorted by id and manual adjustments by time,
+// dates are written as '2006-01-02' (or in RFC3339 format if they have a time of day),
+// and schedules are written the way their parser formats them, such as Weekly(Thursday).
+// Lists are written the same as the README, with their items in line with the field they belong to.
+// Values which can't be parsed are left as they are, so a file with errors can still be formatted.
+func Format(src []byte) ([]byte, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(src))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err == io.EOF {
+		return src, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var next yaml.Node
+	if err := dec.Decode(&next); err != io.EOF {
+		return nil, errors.New("only files with one YAML document can be formatted")
+	}
+	if len(doc.Content) == 0 {
+		return src, nil
+	}
+
+	root := doc.Content[0]
+	formatNode(root, reflect.TypeOf(portfolioSpec{}))
+	if root.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			switch root.Content[i].Value {
+			case "accounts":
+				sortItems(root.Content[i+1], accountLess)
+			case "manualAdjustments":
+				if allTimes(root.Content[i+1]) {
+					sortItems(root.Content[i+1], adjustmentLess)
+				}
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return compactSequences(b.Bytes()), nil
+}
+
+// compactSequences moves the items of each list back in line with the field they belong to,
+// since the encoder indents them (and everything in them) by another level.
+func compactSequences(src []byte) []byte {
+	type sequence struct{ indent, shift int }
+	var (
+		out       bytes.Buffer
+		sequences []sequence
+		block     = -1 // the indent of the line starting a block scalar, while in one
+	)
+	shiftAt := func(indent int) int {
+		for i := len(sequences) - 1; i >= 0; i-- {
+			if sequences[i].indent <= indent {
+				return sequences[i].shift
+			}
+		}
+		return 0
+	}
+
+	lines := strings.SplitAfter(string(src), "\n")
+	for _, line := range lines {
+		text := strings.TrimLeft(line, " ")
+		indent := len(line) - len(text)
+		if strings.TrimSpace(text) == "" {
+			out.WriteString(text)
+			continue
+		}
+		if block >= 0 && indent > block {
+			// Block scalars move along with the field they belong to
+			out.WriteString(line[shiftAt(block):])
+			continue
+		}
+		block = -1
+
+		if !strings.HasPrefix(text, "#") {
+			item := text == "-\n" || strings.HasPrefix(text, "- ")
+			for len(sequences) > 0 {
+				if top := sequences[len(sequences)-1]; top.indent < indent || (item && top.indent == indent) {
+					break
+				}
+				sequences = sequences[:len(sequences)-1]
+			}
+			if item && (len(sequences) == 0 || sequences[len(sequences)-1].indent < indent) {
+				// A list which isn't in a field isn't indented
+				shift := shiftAt(indent)
+				if indent > 0 {
+					shift += 2
+				}
+				sequences = append(sequences, sequence{indent, shift})
+			}
+			if blockScalarRegex.MatchString(strings.TrimRight(text, "\r\n")) {
+				block = indent
+			}
+		}
+
+		shift := shiftAt(indent)
+		if shift > indent {
+			shift = indent
+		}
+		out.WriteString(line[shift:])
+	}
+	return out.Bytes()
+}
+
+// formatNode formats a node in step with the spec type it is decoded into.
+func formatNode(n *yaml.Node, t reflect.Type) {
+	// Anchored nodes are formatted where they are defined
+	if n.Kind == yaml.AliasNode {
+		return
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t {
+	case laxTimeType:
+		formatTime(n)
+		return
+	case jsonScheduleType:
+		formatSchedule(n)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		sortFields(n, t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if f, ok := fieldByYAMLName(t, n.Content[i].Value); ok {
+				formatNode(n.Content[i+1], f.Type)
+			}
+		}
+	case reflect.Slice:
+		if n.Kind != yaml.SequenceNode {
+			return
+		}
+		for _, c := range n.Content {
+			formatNode(c, t.Elem())
+		}
+	case reflect.Map:
+		if n.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 1; i < len(n.Content); i += 2 {
+			formatNode(n.Content[i], t.Elem())
+		}
+	}
+}
+
+// sortFields orders the fields of a mapping the same as the spec, with merge keys first and unknown fields last.
+func sortFields(n *yaml.Node, t reflect.Type) {
+	rank := func(key string) int {
+		if key == "<<" {
+			return -1
+		}
+		for i := 0; i < t.NumField(); i++ {
+			if f := t.Field(i); f.PkgPath == "" && yamlName(f) == key {
+				return i
+			}
+		}
+		return t.NumField()
+	}
+
+	pairs := make([][]*yaml.Node, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		pairs = append(pairs, n.Content[i:i+2])
+	}
+	pairs = reorder(pairs, func(a, b []*yaml.Node) bool {
+		return rank(a[0].Value) < rank(b[0].Value)
+	})
+	content := make([]*yaml.Node, 0, len(n.Content))
+	for _, p := range pairs {
+		content = append(content, p...)
+	}
+	n.Content = content
+}
+
+func formatTime(n *yaml.Node) {
+	if n.Kind != yaml.ScalarNode || strings.HasPrefix(n.Value, "=") {
+		return
+	}
+	t, err := parseLaxTime(n.Value)
+	if err != nil {
+		return
+	}
+	v, _ := laxTime(t).MarshalYAML()
+	n.Tag, n.Value, n.Style = "!!str", v.(string), yaml.SingleQuotedStyle
+}
+
+func formatSchedule(n *yaml.Node) {
+	if n.Kind != yaml.ScalarNode {
+		return
+	}
+	s, err := parseSchedule(n.Value, findScheduleParser)
+	if err != nil {
+		return
+	}
+	text, err := FormatSchedule(s)
+	if err != nil {
+		return
+	}
+	n.Value, n.Style = text, 0
+}
+
+// sortItems sorts the items of a list.
+func sortItems(n *yaml.Node, less func(a, b *yaml.Node) bool) {
+	if n.Kind != yaml.SequenceNode {
+		return
+	}
+	items := make([][]*yaml.Node, len(n.Content))
+	for i, c := range n.Content {
+		items[i] = []*yaml.Node{c}
+	}
+	items = reorder(items, func(a, b []*yaml.Node) bool {
+		return less(resolveAlias(a[0]), resolveAlias(b[0]))
+	})
+	for i, item := range items {
+		n.Content[i] = item[0]
+	}
+}
+
+// reorder sorts groups of nodes, unless that would put an alias before the anchor it uses from another group.
+func reorder(groups [][]*yaml.Node, less func(a, b []*yaml.Node) bool) [][]*yaml.Node {
+	order := make([]int, len(groups))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return less(groups[order[i]], groups[order[j]])
+	})
+	pos := make([]int, len(groups))
+	for p, i := range order {
+		pos[i] = p
+	}
+
+	defined := make(map[*yaml.Node]int)
+	for i, g := range groups {
+		for _, n := range g {
+			walkNodes(n, func(d *yaml.Node) {
+				if d.Anchor != "" {
+					defined[d] = i
+				}
+			})
+		}
+	}
+	valid := true
+	for i, g := range groups {
+		for _, n := range g {
+			walkNodes(n, func(d *yaml.Node) {
+				if j, ok := defined[d.Alias]; d.Kind == yaml.AliasNode && ok && j != i && pos[j] > pos[i] {
+					valid = false
+				}
+			})
+		}
+	}
+	if !valid {
+		return groups
+	}
+
+	sorted := make([][]*yaml.Node, len(groups))
+	for p, i := range order {
+		sorted[p] = groups[i]
+	}
+	return sorted
+}
+
+// walkNodes calls f for a node and every node in it, without following aliases.
+func walkNodes(n *yaml.Node, f func(*yaml.Node)) {
+	f(n)
+	for _, c := range n.Content {
+		walkNodes(c, f)
+	}
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	if n.Kind == yaml.AliasNode {
+		return n.Alias
+	}
+	return n
+}
+
+// fieldValue gets the value of a field in a mapping, or nil if it doesn't have the field.
+func fieldValue(n *yaml.Node, name string) *yaml.Node {
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == name {
+			return resolveAlias(n.Content[i+1])
+		}
+	}
+	return nil
+}
+
+// accountLess orders accounts by id, with numeric ids before string ids and accounts without ids last.
+func accountLess(a, b *yaml.Node) bool {
+	rank := func(n *yaml.Node) (int, int, string) {
+		id := fieldValue(n, "id")
+		if id == nil || id.Kind != yaml.ScalarNode {
+			return 2, 0, ""
+		}
+		if num, err := strconv.Atoi(id.Value); err == nil {
+			return 0, num, ""
+		}
+		return 1, 0, id.Value
+	}
+	ra, na, sa := rank(a)
+	rb, nb, sb := rank(b)
+	if ra != rb {
+		return ra < rb
+	}
+	if na != nb {
+		return na < nb
+	}
+	return sa < sb
+}
+
+// allTimes reports whether every manual adjustment has a time which can be parsed.
+func allTimes(n *yaml.Node) bool {
+	if n.Kind != yaml.SequenceNode {
+		return false
+	}
+	for _, c := range n.Content {
+		t := fieldValue(resolveAlias(c), "time")
+		if t == nil {
+			return false
+		}
+		if _, err := parseLaxTime(t.Value); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func adjustmentLess(a, b *yaml.Node) bool {
+	ta, _ := parseLaxTime(fieldValue(a, "time").Value)
+	tb, _ := parseLaxTime(fieldValue(b, "time").Value)
+	return ta.Before(tb)
+}
@@ -0,0 +1,172 @@
+package munn
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// includeFields are the fields an included file can have, which are merged into the including file.
+var includeFields = map[string]bool{
+	"include":           true,
+	"accounts":          true,
+	"manualAdjustments": true,
+	"transactions":      true,
+	"pensions":          true,
+	"grants":            true,
+	"rebalances":        true,
+}
+
+// Files gets the names of the files the portfolio was read from, starting with the main file and then the files it includes.
+func (p *Portfolio) Files() []string {
+	return p.files
+}
+
+// load reads a file's document and checks its structure, then merges in the files it includes.
+// Paths in include are relative to the including file, and each file is only included once.
+func (ps *parser) load(r io.Reader, file string) *yaml.Node {
+	prevFile, prevDoc := ps.file, ps.doc
+	defer func() {
+		ps.file, ps.doc = prevFile, prevDoc
+	}()
+	ps.file = file
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		ps.errorf(nil, "%v", err)
+		return nil
+	}
+	// Included files are read in the syntax of their extension
+	s := ps.syntax
+	if len(ps.including) > 0 {
+		s = syntaxOf(file)
+	}
+	root, err := s.document(data)
+	if err != nil {
+		ps.yamlError(err)
+		return nil
+	}
+	if root == nil {
+		ps.errorf(nil, "file is empty")
+		return nil
+	}
+	ps.doc = root
+	ps.track(root, file)
+	ps.files = append(ps.files, file)
+
+	if len(ps.including) > 0 && root.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if k := root.Content[i]; !includeFields[k.Value] {
+				ps.errorf(k, "%s can only be in the main file, not an included file", k.Value)
+			}
+		}
+	}
+
+	var spec portfolioSpec
+	ps.checkFields(root, reflect.TypeOf(spec))
+	if len(ps.including) == 0 {
+		if vars := ps.at("vars"); vars != root && vars.Kind == yaml.MappingNode {
+			ps.vars = vars
+			// Evaluate every variable, so errors are found even in unused ones
+			for i := 0; i+1 < len(vars.Content); i += 2 {
+				ps.lookupVar(vars.Content[i].Value)
+			}
+		}
+	}
+	ps.evalExprs(root, reflect.TypeOf(spec))
+	if err := root.Decode(&spec); err != nil {
+		ps.yamlError(err)
+		return nil
+	}
+
+	ps.including = append(ps.including, file)
+	defer func() {
+		ps.including = ps.including[:len(ps.including)-1]
+	}()
+	for i, inc := range spec.Include {
+		n := ps.at("include", i)
+		path := inc
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(file), path)
+		}
+
+		if cycle := ps.cycle(path); cycle != nil {
+			ps.errorf(n, "include cycle: %s", strings.Join(append(cycle, path), " -> "))
+			continue
+		}
+		if ps.included[absPath(path)] {
+			continue
+		}
+		ps.included[absPath(path)] = true
+
+		f, err := os.Open(path)
+		if err != nil {
+			ps.errorf(n, "%v", err)
+			continue
+		}
+		included := ps.load(f, path)
+		f.Close()
+		if included != nil {
+			merge(root, included)
+		}
+	}
+	return root
+}
+
+// cycle gets the files including each other in a cycle, if including the path would cause one.
+func (ps *parser) cycle(path string) []string {
+	for i, f := range ps.including {
+		if absPath(f) == absPath(path) {
+			return ps.including[i:]
+		}
+	}
+	return nil
+}
+
+// track records the file every node is from, so diagnostics can say where they are.
+func (ps *parser) track(n *yaml.Node, file string) {
+	if _, ok := ps.nodeFiles[n]; ok {
+		return
+	}
+	ps.nodeFiles[n] = file
+	for _, c := range n.Content {
+		ps.track(c, file)
+	}
+}
+
+// merge appends the items of every list in an included document to the same list in the including document.
+func merge(root, included *yaml.Node) {
+	for i := 0; i+1 < len(included.Content); i += 2 {
+		k, v := included.Content[i], included.Content[i+1]
+		if k.Value == "include" || v.Kind != yaml.SequenceNode {
+			continue
+		}
+		var existing *yaml.Node
+		for j := 0; j+1 < len(root.Content); j += 2 {
+			if root.Content[j].Value == k.Value {
+				existing = root.Content[j+1]
+			}
+		}
+		switch {
+		case existing == nil:
+			root.Content = append(root.Content, k, v)
+		case existing.Kind == yaml.SequenceNode:
+			existing.Content = append(existing.Content, v.Content...)
+		default:
+			*existing = *v
+		}
+	}
+}
+
+func absPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
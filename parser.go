@@ -3,12 +3,15 @@ package munn
 import (
 	"fmt"
 	"io"
+	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -32,6 +35,19 @@ func RegisterScheduleParser(name string, parser ScheduleParser) {
 	scheduleParsers[name] = parser
 }
 
+// ScheduleParserNames gets the names of the registered schedule parsers, in sorted order.
+func ScheduleParserNames() []string {
+	scheduleParsersLock.Lock()
+	defer scheduleParsersLock.Unlock()
+
+	names := make([]string, 0, len(scheduleParsers))
+	for name := range scheduleParsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // GetScheduleParser gets a schedule parser
 func GetScheduleParser(name string) (ScheduleParser, bool) {
 	scheduleParsersLock.Lock()
@@ -45,121 +61,612 @@ func GetScheduleParser(name string) (ScheduleParser, bool) {
 }
 
 // Parse will read a portfolio from an io.Reader.
+// If the portfolio has any errors, they are all returned as Diagnostics.
 func Parse(r io.Reader) (*Portfolio, error) {
+	p, diags := Validate(r)
+	if errs := diags.Errors(); len(errs) > 0 {
+		return nil, errs
+	}
+	return p, nil
+}
+
+// ParseJSON will read a portfolio written in JSON from an io.Reader.
+// If the portfolio has any errors, they are all returned as Diagnostics.
+func ParseJSON(r io.Reader) (*Portfolio, error) {
+	return parseSyntax(r, syntaxJSON)
+}
+
+// ParseTOML will read a portfolio written in TOML from an io.Reader.
+// If the portfolio has any errors, they are all returned as Diagnostics.
+func ParseTOML(r io.Reader) (*Portfolio, error) {
+	return parseSyntax(r, syntaxTOML)
+}
+
+func parseSyntax(r io.Reader, s syntax) (*Portfolio, error) {
+	p, diags := validate(r, "", s)
+	if errs := diags.Errors(); len(errs) > 0 {
+		return nil, errs
+	}
+	return p, nil
+}
+
+// ParseFile will read a portfolio from a file, along with the files it includes.
+// Files ending in .json or .toml are read as JSON or TOML, and any other file as YAML.
+// If the portfolio has any errors, they are all returned as Diagnostics.
+func ParseFile(name string) (*Portfolio, error) {
+	p, diags := ValidateFile(name)
+	if errs := diags.Errors(); len(errs) > 0 {
+		return nil, errs
+	}
+	return p, nil
+}
+
+// Validate will read a portfolio from an io.Reader, finding every error and warning in it.
+// Included files are relative to the working directory.
+// The portfolio is nil if there are any errors.
+func Validate(r io.Reader) (*Portfolio, Diagnostics) {
+	return validate(r, "", syntaxYAML)
+}
+
+// ValidateFile will read a portfolio from a file, along with the files it includes, finding every error and warning in them.
+// The portfolio is nil if there are any errors.
+func ValidateFile(name string) (*Portfolio, Diagnostics) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, Diagnostics{{File: name, Severity: SeverityError, Message: err.Error()}}
+	}
+	defer f.Close()
+	return validate(f, name, syntaxOf(name))
+}
+
+// ValidateSource will read a portfolio from an io.Reader as if it were the named file, finding every error and warning in it.
+// It is read in the syntax of the file's extension, and included files are relative to it.
+// The portfolio is nil if there are any errors.
+func ValidateSource(name string, r io.Reader) (*Portfolio, Diagnostics) {
+	return validate(r, name, syntaxOf(name))
+}
+
+func validate(r io.Reader, name string, s syntax) (*Portfolio, Diagnostics) {
+	ps := &parser{
+		file:      name,
+		syntax:    s,
+		nodeFiles: make(map[*yaml.Node]string),
+		varValues: make(map[string]exprValue),
+		varFailed: make(map[string]bool),
+		included:  map[string]bool{absPath(name): true},
+	}
+	p := ps.parse(r)
+	ps.sort()
+	if len(ps.diags.Errors()) > 0 {
+		return nil, ps.diags
+	}
+	p.files = ps.files
+	return p, ps.diags
+}
+
+func (ps *parser) parse(r io.Reader) *Portfolio {
+	// Problems with the files' structure would cause confusing errors later, so stop after finding all of them
+	ps.doc = ps.load(r, ps.file)
+	if ps.doc == nil || len(ps.diags) > 0 {
+		return nil
+	}
 	var spec portfolioSpec
-	if err := yaml.NewDecoder(r).Decode(&spec); err != nil {
-		return nil, err
+	if err := ps.doc.Decode(&spec); err != nil {
+		ps.yamlError(err)
+		return nil
 	}
 
 	p := &Portfolio{}
 
 	if spec.YearsToProject != nil {
 		if *spec.YearsToProject <= 0 {
-			return nil, fmt.Errorf("yearsToProject must be positive")
+			ps.errorf(ps.at("yearsToProject"), "yearsToProject must be positive")
 		}
 		p.YearsToProject = spec.YearsToProject
 	}
 
-	accountsMap := make(map[int]*Account)
-
-	for _, accSpec := range spec.Accounts {
-		if _, ok := accountsMap[accSpec.ID]; ok {
-			return nil, fmt.Errorf("duplicate account ID: %d", accSpec.ID)
+	if spec.RMD.StartAge != nil {
+		if *spec.RMD.StartAge <= 0 {
+			ps.errorf(ps.at("rmd", "startAge"), "rmd startAge must be positive")
+		}
+		p.RMDStartAge = *spec.RMD.StartAge
+	}
+	if len(spec.RMD.Table) > 0 {
+		p.RMDTable = make(map[int]float32)
+		for age, period := range UniformLifetimeTable {
+			p.RMDTable[age] = period
+		}
+		for age, period := range spec.RMD.Table {
+			if period <= 0 {
+				ps.errorf(ps.at("rmd", "table", strconv.Itoa(age)), "rmd table period for age %d must be positive", age)
+			}
+			p.RMDTable[age] = period
 		}
+	}
+
+	accounts := newAccountResolver()
+
+	idNodes := make(map[accountRef]*yaml.Node)
+	nameNodes := make(map[string]*yaml.Node)
+	for i, accSpec := range spec.Accounts {
 		acc := p.NewAccount(accSpec.Name)
-		accountsMap[accSpec.ID] = acc
+		ps.defineAccount(p, i, acc)
+		idNode, nameNode := ps.at("accounts", i, "id"), ps.at("accounts", i, "name")
+		if err := accounts.add(accSpec.ID, acc); err != nil {
+			ps.errorf(idNode, "%v (also used %s)", err, ps.where(idNodes[accSpec.ID], idNode))
+		} else if accSpec.ID != "" {
+			idNodes[accSpec.ID] = idNode
+		}
+		// Accounts with the same name can be told apart by id in one file, but are probably a mistake across files
+		if n, ok := nameNodes[accSpec.Name]; ok && ps.nodeFiles[n] != ps.nodeFiles[nameNode] {
+			ps.errorf(nameNode, "duplicate account name: %s (also used %s)", accSpec.Name, ps.where(n, nameNode))
+		} else if !ok {
+			nameNodes[accSpec.Name] = nameNode
+		}
+		acc.Group = accSpec.Group
+		acc.Tags = accSpec.Tags
 
 		if accSpec.AnnualInterestRate != 0 {
 			acc.AnnualInterestRate = accSpec.AnnualInterestRate
 		}
+		if accSpec.TaxTreatment != "" {
+			t, err := ParseTaxTreatment(accSpec.TaxTreatment)
+			if err != nil {
+				ps.errorf(ps.at("accounts", i, "taxTreatment"), "%v", err)
+			}
+			acc.TaxTreatment = t
+		}
+		if accSpec.CostBasis != "" {
+			m, err := ParseCostBasisMethod(accSpec.CostBasis)
+			if err != nil {
+				ps.errorf(ps.at("accounts", i, "costBasis"), "%v", err)
+			}
+			acc.CostBasisMethod = m
+		}
+		acc.AnnualContributionLimit = accSpec.ContributionLimit
+		acc.ContributionLimitGrowth = accSpec.LimitGrowth
+		acc.OwnerBirthDate = (*time.Time)(accSpec.OwnerBirthDate)
 	}
 
-	for _, man := range spec.ManualAdjustments {
-		acc, ok := accountsMap[man.Account]
-		if !ok {
-			return nil, fmt.Errorf("invalid account: %d", man.Account)
+	for i, accSpec := range spec.Accounts {
+		if accSpec.RMDAccount != "" {
+			rmdAcc, err := accounts.resolve(accSpec.RMDAccount)
+			if err != nil {
+				ps.errorf(ps.at("accounts", i, "rmdAccount"), "account '%s' rmdAccount: %v", accSpec.Name, err)
+			}
+			ps.refAccount(p, ps.at("accounts", i, "rmdAccount"), rmdAcc)
+			p.Accounts[i].RMDAccount = rmdAcc
+		}
+	}
+
+	for i, man := range spec.ManualAdjustments {
+		acc, err := accounts.resolve(man.Account)
+		if err != nil {
+			ps.errorf(ps.at("manualAdjustments", i, "account"), "manual adjustment: %v", err)
+			continue
 		}
+		ps.refAccount(p, ps.at("manualAdjustments", i, "account"), acc)
 		if man.Balance == nil {
-			return nil, fmt.Errorf("manual adjustment missing balance")
+			ps.errorf(ps.at("manualAdjustments", i), "manual adjustment missing balance")
+			continue
 		}
-		p.NewManualAdjustment(acc, time.Time(man.Time), *man.Balance)
+		m := p.NewManualAdjustment(acc, time.Time(man.Time), *man.Balance)
+		m.Basis = man.Basis
 	}
 
-	for _, trans := range spec.Transactions {
+	var firstAdjustment time.Time
+	for _, man := range spec.ManualAdjustments {
+		if t := time.Time(man.Time); firstAdjustment.IsZero() || t.Before(firstAdjustment) {
+			firstAdjustment = t
+		}
+	}
+	if len(spec.ManualAdjustments) == 0 {
+		ps.errorf(nil, "at least one manual adjustment is required to start the projection")
+	}
+
+	descriptions := make(map[string]*yaml.Node)
+	// Salaries can be any transaction, so they are found once all of them are read
+	byDescription := make(map[string]*Transaction)
+	specIndex := make(map[*Transaction]int)
+	for i, trans := range spec.Transactions {
+		if n, ok := descriptions[trans.Description]; ok {
+			ps.warnf(ps.at("transactions", i, "description"), "transaction '%s' has the same description as the transaction %s", trans.Description, ps.where(n, ps.at("transactions", i, "description")))
+		} else {
+			descriptions[trans.Description] = ps.at("transactions", i, "description")
+		}
+
 		var from []*Account
 		var to *Account
-		var fromAccounts []int
-		switch a := trans.FromAccount.(type) {
-		case nil:
-		case int:
-			fromAccounts = []int{a}
-		case []interface{}:
-			for _, v := range a {
-				a, ok := v.(int)
-				if !ok {
-					return nil, fmt.Errorf("invalid fromAccount: %v", trans.FromAccount)
-				}
-				fromAccounts = append(fromAccounts, a)
-			}
-		default:
-			return nil, fmt.Errorf("invalid fromAccount: %v", trans.FromAccount)
-		}
-		for _, a := range fromAccounts {
-			acc, ok := accountsMap[a]
-			if !ok {
-				return nil, fmt.Errorf("invalid account: %d", trans.FromAccount)
+		valid := true
+		for j, a := range trans.FromAccount {
+			acc, err := accounts.resolve(a)
+			if err != nil {
+				ps.errorf(ps.at("transactions", i, "fromAccount", j), "transaction '%s' fromAccount: %v", trans.Description, err)
+				valid = false
 			}
+			ps.refAccount(p, ps.at("transactions", i, "fromAccount", j), acc)
 			from = append(from, acc)
 		}
-		if trans.ToAccount != 0 {
-			var ok bool
-			to, ok = accountsMap[trans.ToAccount]
-			if !ok {
-				return nil, fmt.Errorf("invalid account: %d", trans.ToAccount)
+		if trans.ToAccount != "" && trans.ToAccount != noAccount {
+			var err error
+			to, err = accounts.resolve(trans.ToAccount)
+			if err != nil {
+				ps.errorf(ps.at("transactions", i, "toAccount"), "transaction '%s' toAccount: %v", trans.Description, err)
+				valid = false
 			}
+			ps.refAccount(p, ps.at("transactions", i, "toAccount"), to)
 		}
 		if trans.Schedule.parsed == nil {
-			return nil, fmt.Errorf("transaction '%s' missing schedule", trans.Description)
+			ps.errorf(ps.at("transactions", i), "transaction '%s' missing schedule", trans.Description)
+			valid = false
+		}
+		if trans.Amount == nil && trans.SalaryRate == nil {
+			ps.errorf(ps.at("transactions", i), "transaction '%s' missing amount", trans.Description)
+			valid = false
+		}
+		if trans.Amount != nil && trans.SalaryRate != nil {
+			ps.errorf(ps.at("transactions", i, "salaryRate"), "transaction '%s' has both an amount and a salaryRate", trans.Description)
+			valid = false
+		}
+		if trans.SalaryRate != nil && trans.Salary == "" {
+			ps.errorf(ps.at("transactions", i, "salaryRate"), "transaction '%s' with a salaryRate is missing salary", trans.Description)
+			valid = false
+		}
+		if trans.Match != nil && trans.ToAccount == "" {
+			ps.errorf(ps.at("transactions", i, "match"), "transaction '%s' with a match is missing toAccount", trans.Description)
+			valid = false
+		}
+		if trans.Match != nil && trans.Match.UpTo != 0 && trans.Salary == "" {
+			ps.errorf(ps.at("transactions", i, "match", "upTo"), "transaction '%s' with a match upTo is missing salary (upTo is a share of the salary)", trans.Description)
+			valid = false
 		}
-		if trans.Amount == nil {
-			return nil, fmt.Errorf("transaction '%s' missing amount", trans.Description)
+		if !valid {
+			continue
 		}
-		p.NewTransaction(from, to, trans.Description, trans.Schedule.parsed, (*time.Time)(trans.Start), (*time.Time)(trans.Stop), *trans.Amount)
+
+		var amount float32
+		if trans.Amount != nil {
+			amount = *trans.Amount
+		}
+		if amount < 0 {
+			ps.warnf(ps.at("transactions", i, "amount"), "transaction '%s' has a negative amount", trans.Description)
+		}
+		once, isOnce := trans.Schedule.parsed.(*onceSchedule)
+		switch {
+		case trans.Start != nil && trans.Stop != nil && !time.Time(*trans.Start).Before(time.Time(*trans.Stop)):
+			ps.warnf(ps.at("transactions", i, "stop"), "transaction '%s' stops before it starts, so it never applies", trans.Description)
+		case trans.Stop != nil && !time.Time(*trans.Stop).After(firstAdjustment):
+			ps.warnf(ps.at("transactions", i, "stop"), "transaction '%s' stops before the first manual adjustment, so it never applies", trans.Description)
+		case isOnce && ((trans.Start != nil && once.time.Before(time.Time(*trans.Start))) || (trans.Stop != nil && !once.time.Before(time.Time(*trans.Stop)))):
+			ps.warnf(ps.at("transactions", i, "schedule"), "transaction '%s' is scheduled outside its start and stop, so it never applies", trans.Description)
+		case isOnce && once.time.Before(firstAdjustment):
+			ps.warnf(ps.at("transactions", i, "schedule"), "transaction '%s' is scheduled before the first manual adjustment, so it never applies", trans.Description)
+		}
+
+		t := p.NewTransaction(from, to, trans.Description, trans.Schedule.parsed, (*time.Time)(trans.Start), (*time.Time)(trans.Stop), amount)
+		ps.defineTransaction(p, ps.at("transactions", i), t)
+		t.Category = trans.Category
+		t.SubCategory = trans.SubCategory
+		t.AnnualGrowth = trans.Growth
+		t.Taxable = trans.Taxable
+		t.Deductible = trans.Deductible
+		if _, ok := byDescription[trans.Description]; !ok {
+			byDescription[trans.Description] = t
+		}
+		specIndex[t] = i
 	}
 
-	return p, nil
+	// Matches are added after the salaries are found, since they only match up to a share of the salary
+	for _, t := range append([]*Transaction(nil), p.Transactions...) {
+		i, ok := specIndex[t]
+		if !ok {
+			continue
+		}
+		trans := spec.Transactions[i]
+		if trans.Salary != "" {
+			salary, ok := byDescription[trans.Salary]
+			switch {
+			case !ok:
+				ps.errorf(ps.at("transactions", i, "salary"), "transaction '%s' salary: no transaction with description '%s'", trans.Description, trans.Salary)
+				continue
+			case salary == t:
+				ps.errorf(ps.at("transactions", i, "salary"), "transaction '%s' can't be its own salary", trans.Description)
+				continue
+			case spec.Transactions[specIndex[salary]].SalaryRate != nil:
+				ps.errorf(ps.at("transactions", i, "salary"), "transaction '%s' salary: '%s' is itself a share of a salary", trans.Description, trans.Salary)
+				continue
+			}
+			t.Salary = salary
+			if trans.SalaryRate != nil {
+				t.SalaryRate = *trans.SalaryRate
+				t.Amount = salary.Amount * t.SalaryRate
+			}
+		}
+		if trans.Match != nil {
+			t.NewEmployerMatch(trans.Match.Rate, trans.Match.UpTo)
+		}
+	}
+
+	for i, pen := range spec.Pensions {
+		to, err := accounts.resolve(pen.ToAccount)
+		if err != nil {
+			ps.errorf(ps.at("pensions", i, "toAccount"), "pension '%s' toAccount: %v", pen.Description, err)
+			continue
+		}
+		ps.refAccount(p, ps.at("pensions", i, "toAccount"), to)
+		if pen.Benefit == nil {
+			ps.errorf(ps.at("pensions", i), "pension '%s' missing benefit", pen.Description)
+			continue
+		}
+		if pen.BirthDate == nil {
+			ps.errorf(ps.at("pensions", i), "pension '%s' missing birthDate", pen.Description)
+			continue
+		}
+		if pen.ClaimDate == nil {
+			ps.errorf(ps.at("pensions", i), "pension '%s' missing claimDate", pen.Description)
+			continue
+		}
+		newPen := p.NewPension(to, pen.Description, *pen.Benefit, time.Time(*pen.BirthDate), time.Time(*pen.ClaimDate))
+		if pen.FullRetirementAge != nil {
+			newPen.FullRetirementAge = *pen.FullRetirementAge
+		}
+		newPen.COLA = pen.COLA
+		newPen.Taxable = pen.Taxable
+	}
+
+	for i, g := range spec.Grants {
+		to, err := accounts.resolve(g.ToAccount)
+		if err != nil {
+			ps.errorf(ps.at("grants", i, "toAccount"), "grant '%s' toAccount: %v", g.Description, err)
+			continue
+		}
+		ps.refAccount(p, ps.at("grants", i, "toAccount"), to)
+		if g.Shares == nil {
+			ps.errorf(ps.at("grants", i), "grant '%s' missing shares", g.Description)
+			continue
+		}
+		if g.Price == nil {
+			ps.errorf(ps.at("grants", i), "grant '%s' missing price", g.Description)
+			continue
+		}
+		if g.Start == nil {
+			ps.errorf(ps.at("grants", i), "grant '%s' missing start", g.Description)
+			continue
+		}
+		grant := &VestingGrant{
+			Description:    g.Description,
+			Shares:         *g.Shares,
+			Price:          *g.Price,
+			PriceGrowth:    g.PriceGrowth,
+			Start:          time.Time(*g.Start),
+			CliffMonths:    12,
+			VestingMonths:  48,
+			IntervalMonths: 3,
+			Withholding:    g.Withholding,
+			Taxable:        g.Taxable,
+		}
+		if g.CliffMonths != nil {
+			grant.CliffMonths = *g.CliffMonths
+		}
+		if g.VestingMonths != nil {
+			grant.VestingMonths = *g.VestingMonths
+		}
+		if g.IntervalMonths != nil {
+			grant.IntervalMonths = *g.IntervalMonths
+		}
+		if grant.VestingMonths <= 0 || grant.IntervalMonths <= 0 {
+			ps.errorf(ps.at("grants", i), "grant '%s' vestingMonths and intervalMonths must be positive", g.Description)
+			continue
+		}
+		p.NewVestingGrant(to, grant)
+	}
+
+	for i, r := range spec.Rebalances {
+		if r.Schedule.parsed == nil {
+			ps.errorf(ps.at("rebalances", i), "rebalance '%s' missing schedule", r.Description)
+			continue
+		}
+		var targets []RebalanceTarget
+		var total float32
+		valid := true
+		for j, t := range r.Targets {
+			acc, err := accounts.resolve(t.Account)
+			if err != nil {
+				ps.errorf(ps.at("rebalances", i, "targets", j, "account"), "rebalance '%s' target: %v", r.Description, err)
+				valid = false
+			}
+			ps.refAccount(p, ps.at("rebalances", i, "targets", j, "account"), acc)
+			targets = append(targets, RebalanceTarget{
+				Account: acc,
+				Percent: t.Percent,
+			})
+			total += t.Percent
+		}
+		if total < 0.999 || total > 1.001 {
+			ps.errorf(ps.at("rebalances", i, "targets"), "rebalance '%s' target percents must add up to 1", r.Description)
+			valid = false
+		}
+		if valid {
+			p.NewRebalance(r.Description, r.Schedule.parsed, targets, r.Tolerance)
+		}
+	}
+
+	if spec.Taxes != nil {
+		p.Taxes = &Taxes{
+			Inflation: spec.Taxes.Inflation,
+		}
+		if spec.Taxes.Account != "" {
+			acc, err := accounts.resolve(spec.Taxes.Account)
+			if err != nil {
+				ps.errorf(ps.at("taxes", "account"), "taxes account: %v", err)
+			}
+			ps.refAccount(p, ps.at("taxes", "account"), acc)
+			p.Taxes.Account = acc
+		}
+		for i, y := range spec.Taxes.Years {
+			if n := len(p.Taxes.Tables); n > 0 && p.Taxes.Tables[n-1].Year >= y.Year {
+				ps.errorf(ps.at("taxes", "years", i, "year"), "tax years must be in increasing order: %d", y.Year)
+			}
+			table := TaxTable{
+				Year:              y.Year,
+				StandardDeduction: y.StandardDeduction,
+			}
+			for j, b := range y.Brackets {
+				if n := len(table.Brackets); n > 0 && table.Brackets[n-1].Over >= b.Over {
+					ps.errorf(ps.at("taxes", "years", i, "brackets", j), "tax brackets for %d must be in increasing order", y.Year)
+				}
+				table.Brackets = append(table.Brackets, TaxBracket{
+					Over: b.Over,
+					Rate: b.Rate,
+				})
+			}
+			p.Taxes.Tables = append(p.Taxes.Tables, table)
+		}
+	}
+
+	// A reference which couldn't be resolved was probably meant for one of the unused accounts
+	for i, acc := range p.Accounts {
+		if !accounts.failed && !accounts.used[acc] {
+			ps.warnf(ps.at("accounts", i), "account '%s' is never used", acc.Name)
+		}
+	}
+
+	return p
 }
 
 type portfolioSpec struct {
-	YearsToProject *int `yaml:"yearsToProject"`
-	Accounts       []struct {
-		ID                 int     `yaml:"id"`
-		Name               string  `yaml:"name"`
-		AnnualInterestRate float32 `yaml:"annualInterestRate"`
-	}
-	ManualAdjustments []struct {
-		Account int      `yaml:"account"`
-		Time    laxTime  `yaml:"time"`
-		Balance *float32 `yaml:"balance"`
-	} `yaml:"manualAdjustments"`
-	Transactions []struct {
-		FromAccount interface{}  `yaml:"fromAccount"`
-		ToAccount   int          `yaml:"toAccount"`
-		Description string       `yaml:"description"`
-		Amount      *float32     `yaml:"amount"`
-		Schedule    jsonSchedule `yaml:"schedule"`
-		Start       *laxTime     `yaml:"start"`
-		Stop        *laxTime     `yaml:"stop"`
-	} `yaml:"transactions"`
+	Include           []string               `yaml:"include,omitempty"`
+	Vars              map[string]interface{} `yaml:"vars,omitempty"`
+	YearsToProject    *int                   `yaml:"yearsToProject,omitempty"`
+	RMD               rmdSpec                `yaml:"rmd,omitempty"`
+	Accounts          []accountSpec          `yaml:"accounts,omitempty"`
+	ManualAdjustments []manualAdjustmentSpec `yaml:"manualAdjustments,omitempty"`
+	Transactions      []transactionSpec      `yaml:"transactions,omitempty"`
+	Pensions          []pensionSpec          `yaml:"pensions,omitempty"`
+	Grants            []grantSpec            `yaml:"grants,omitempty"`
+	Rebalances        []rebalanceSpec        `yaml:"rebalances,omitempty"`
+	Taxes             *taxesSpec             `yaml:"taxes,omitempty"`
+}
+
+type rmdSpec struct {
+	StartAge *int            `yaml:"startAge,omitempty"`
+	Table    map[int]float32 `yaml:"table,omitempty"`
+}
+
+type accountSpec struct {
+	ID                 accountRef `yaml:"id,omitempty"`
+	Name               string     `yaml:"name"`
+	Group              string     `yaml:"group,omitempty"`
+	Tags               []string   `yaml:"tags,omitempty"`
+	AnnualInterestRate float32    `yaml:"annualInterestRate,omitempty"`
+	TaxTreatment       string     `yaml:"taxTreatment,omitempty"`
+	CostBasis          string     `yaml:"costBasis,omitempty"`
+	ContributionLimit  float32    `yaml:"contributionLimit,omitempty"`
+	LimitGrowth        float32    `yaml:"contributionLimitGrowth,omitempty"`
+	OwnerBirthDate     *laxTime   `yaml:"ownerBirthDate,omitempty"`
+	RMDAccount         accountRef `yaml:"rmdAccount,omitempty"`
+}
+
+type manualAdjustmentSpec struct {
+	Account accountRef `yaml:"account"`
+	Time    laxTime    `yaml:"time"`
+	Balance *float32   `yaml:"balance"`
+	Basis   *float32   `yaml:"basis,omitempty"`
+}
+
+type transactionSpec struct {
+	FromAccount accountRefs  `yaml:"fromAccount,omitempty"`
+	ToAccount   accountRef   `yaml:"toAccount,omitempty"`
+	Description string       `yaml:"description"`
+	Category    string       `yaml:"category,omitempty"`
+	SubCategory string       `yaml:"subCategory,omitempty"`
+	Amount      *float32     `yaml:"amount,omitempty"`
+	Salary      string       `yaml:"salary,omitempty"`
+	SalaryRate  *float32     `yaml:"salaryRate,omitempty"`
+	Growth      float32      `yaml:"growth,omitempty"`
+	Schedule    jsonSchedule `yaml:"schedule"`
+	Start       *laxTime     `yaml:"start,omitempty"`
+	Stop        *laxTime     `yaml:"stop,omitempty"`
+	Taxable     bool         `yaml:"taxable,omitempty"`
+	Deductible  bool         `yaml:"deductible,omitempty"`
+	Match       *matchSpec   `yaml:"match,omitempty"`
+}
+
+type matchSpec struct {
+	Rate float32 `yaml:"rate"`
+	UpTo float32 `yaml:"upTo,omitempty"`
+}
+
+type pensionSpec struct {
+	ToAccount         accountRef `yaml:"toAccount"`
+	Description       string     `yaml:"description"`
+	Benefit           *float32   `yaml:"benefit"`
+	BirthDate         *laxTime   `yaml:"birthDate"`
+	ClaimDate         *laxTime   `yaml:"claimDate"`
+	FullRetirementAge *int       `yaml:"fullRetirementAge,omitempty"`
+	COLA              float32    `yaml:"cola,omitempty"`
+	Taxable           bool       `yaml:"taxable,omitempty"`
+}
+
+type grantSpec struct {
+	ToAccount      accountRef `yaml:"toAccount"`
+	Description    string     `yaml:"description"`
+	Shares         *float32   `yaml:"shares"`
+	Price          *float32   `yaml:"price"`
+	PriceGrowth    float32    `yaml:"priceGrowth,omitempty"`
+	Start          *laxTime   `yaml:"start"`
+	CliffMonths    *int       `yaml:"cliffMonths,omitempty"`
+	VestingMonths  *int       `yaml:"vestingMonths,omitempty"`
+	IntervalMonths *int       `yaml:"intervalMonths,omitempty"`
+	Withholding    float32    `yaml:"withholding,omitempty"`
+	Taxable        bool       `yaml:"taxable,omitempty"`
+}
+
+type rebalanceSpec struct {
+	Description string                `yaml:"description"`
+	Schedule    jsonSchedule          `yaml:"schedule"`
+	Tolerance   float32               `yaml:"tolerance,omitempty"`
+	Targets     []rebalanceTargetSpec `yaml:"targets"`
+}
+
+type rebalanceTargetSpec struct {
+	Account accountRef `yaml:"account"`
+	Percent float32    `yaml:"percent"`
+}
+
+type taxesSpec struct {
+	Account   accountRef    `yaml:"account,omitempty"`
+	Inflation float32       `yaml:"inflation,omitempty"`
+	Years     []taxYearSpec `yaml:"years"`
+}
+
+type taxYearSpec struct {
+	Year              int              `yaml:"year"`
+	StandardDeduction float32          `yaml:"standardDeduction,omitempty"`
+	Brackets          []taxBracketSpec `yaml:"brackets"`
+}
+
+type taxBracketSpec struct {
+	Over float32 `yaml:"over"`
+	Rate float32 `yaml:"rate"`
 }
 
 type laxTime time.Time
 
-func (l *laxTime) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	var s string
-	if err := unmarshal(&s); err != nil {
-		return err
+func (l *laxTime) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return nodeError(value, "invalid time")
+	}
+	t, err := parseLaxTime(value.Value)
+	if err != nil {
+		return nodeError(value, "%v", err)
 	}
+	*l = laxTime(t)
+	return nil
+}
+
+// parseLaxTime parses a date, or a time in RFC3339 format.
+func parseLaxTime(s string) (time.Time, error) {
 	formats := []string{
 		"2006-01-02",
 		time.RFC3339,
@@ -169,11 +676,177 @@ func (l *laxTime) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	for _, f := range formats {
 		t, err = time.Parse(f, s)
 		if err == nil {
-			*l = laxTime(t)
-			return nil
+			return t, nil
+		}
+	}
+	return t, fmt.Errorf("failed to parse time as any of the valid formats: last error: %v", err)
+}
+
+// MarshalYAML encodes the time as a date if it has no time of day.
+func (l laxTime) MarshalYAML() (interface{}, error) {
+	t := time.Time(l)
+	if t.Location() == time.UTC && t.Equal(t.Truncate(24*time.Hour)) {
+		return t.Format("2006-01-02"), nil
+	}
+	return t.Format(time.RFC3339), nil
+}
+
+func (l laxTime) IsZero() bool {
+	return time.Time(l).IsZero()
+}
+
+// accountRef refers to an account by its id (a number or a string key) or its name.
+type accountRef string
+
+// noAccount is a transaction's toAccount for money leaving the portfolio, the same as leaving it out.
+const noAccount accountRef = "0"
+
+func (r *accountRef) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode || (value.Tag != "!!int" && value.Tag != "!!str") {
+		return nodeError(value, "invalid account reference: %s", value.Value)
+	}
+	if value.Value == "" {
+		return nodeError(value, "account reference cannot be empty")
+	}
+	*r = accountRef(value.Value)
+	return nil
+}
+
+// MarshalYAML encodes numeric references as numbers.
+func (r accountRef) MarshalYAML() (interface{}, error) {
+	if n, err := strconv.Atoi(string(r)); err == nil && strconv.Itoa(n) == string(r) {
+		return n, nil
+	}
+	return string(r), nil
+}
+
+// accountRefs is one account reference or a list of them.
+type accountRefs []accountRef
+
+func (r *accountRefs) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		var refs []accountRef
+		if err := value.Decode(&refs); err != nil {
+			return err
+		}
+		*r = refs
+		return nil
+	}
+	var ref accountRef
+	if err := value.Decode(&ref); err != nil {
+		return err
+	}
+	*r = accountRefs{ref}
+	return nil
+}
+
+// MarshalYAML encodes a single reference without a list.
+func (r accountRefs) MarshalYAML() (interface{}, error) {
+	if len(r) == 1 {
+		return r[0], nil
+	}
+	return []accountRef(r), nil
+}
+
+// accountResolver finds the accounts that account references refer to.
+// Ids take precedence over names, and names shared by several accounts can only be referred to by id.
+type accountResolver struct {
+	byID   map[accountRef]*Account
+	byName map[string][]*Account
+	refs   []string
+	used   map[*Account]bool
+	failed bool
+}
+
+func newAccountResolver() *accountResolver {
+	return &accountResolver{
+		byID:   make(map[accountRef]*Account),
+		byName: make(map[string][]*Account),
+		used:   make(map[*Account]bool),
+	}
+}
+
+func (r *accountResolver) add(id accountRef, acc *Account) error {
+	if id != "" {
+		if _, ok := r.byID[id]; ok {
+			return fmt.Errorf("duplicate account ID: %s", id)
+		}
+		r.byID[id] = acc
+		r.refs = append(r.refs, string(id))
+	}
+	if len(r.byName[acc.Name]) == 0 {
+		r.refs = append(r.refs, acc.Name)
+	}
+	r.byName[acc.Name] = append(r.byName[acc.Name], acc)
+	return nil
+}
+
+func (r *accountResolver) resolve(ref accountRef) (*Account, error) {
+	if acc, ok := r.byID[ref]; ok {
+		r.used[acc] = true
+		return acc, nil
+	}
+	switch accs := r.byName[string(ref)]; len(accs) {
+	case 0:
+	case 1:
+		r.used[accs[0]] = true
+		return accs[0], nil
+	default:
+		r.failed = true
+		return nil, fmt.Errorf("account name '%s' is ambiguous, refer to it by id", ref)
+	}
+	r.failed = true
+	if s, ok := closest(string(ref), r.refs); ok {
+		return nil, fmt.Errorf("unknown account '%s' (did you mean '%s'?)", ref, s)
+	}
+	return nil, fmt.Errorf("unknown account '%s'", ref)
+}
+
+// closest finds the option most similar to s, if any are similar enough to be a likely typo.
+func closest(s string, options []string) (string, bool) {
+	best, bestDist := "", -1
+	for _, o := range options {
+		d := editDistance(strings.ToLower(s), strings.ToLower(o))
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = o, d
 		}
 	}
-	return fmt.Errorf("failed to parse time as any of the valid formats: last error: %v", err)
+	if bestDist < 0 || bestDist > len(s)/2 {
+		return "", false
+	}
+	return best, true
+}
+
+// editDistance is the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	cur := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		cur[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			cur[j] = min3(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(br)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
 }
 
 type jsonSchedule struct {
@@ -192,20 +865,94 @@ var daysOfWeek = map[string]time.Weekday{
 	"saturday":  time.Saturday,
 }
 
+var monthsOfYear = map[string]time.Month{
+	"january":   time.January,
+	"february":  time.February,
+	"march":     time.March,
+	"april":     time.April,
+	"may":       time.May,
+	"june":      time.June,
+	"july":      time.July,
+	"august":    time.August,
+	"september": time.September,
+	"october":   time.October,
+	"november":  time.November,
+	"december":  time.December,
+}
+
 // ScheduleParser parses a schedule
 type ScheduleParser interface {
 	ParseSchedule(args []string) (Schedule, error)
 }
 
-func (f *jsonSchedule) UnmarshalYAML(unmarshal func(interface{}) error) error {
-	var s string
-	if err := unmarshal(&s); err != nil {
-		return err
+// ScheduleFormatter formats a schedule as the arguments its ScheduleParser would parse it from.
+// A ScheduleParser should also implement it, so the schedules it parses can be encoded by Marshal.
+// It returns false for schedules it doesn't know how to format.
+type ScheduleFormatter interface {
+	FormatSchedule(s Schedule) (args []string, ok bool)
+}
+
+// findScheduleParser finds a schedule parser by its name, ignoring case if there isn't one with the exact name.
+// Only Format ignores case, to fix names like monthly; files must use the registered name.
+func findScheduleParser(name string) (ScheduleParser, bool) {
+	if parser, ok := GetScheduleParser(name); ok {
+		return parser, true
 	}
 
+	scheduleParsersLock.Lock()
+	defer scheduleParsersLock.Unlock()
+
+	for n, parser := range scheduleParsers {
+		if strings.EqualFold(n, name) {
+			return parser, true
+		}
+	}
+	return nil, false
+}
+
+// FormatSchedule formats a schedule as text, such as Weekly(Thursday) or Monthly(15),
+// using the first registered schedule parser (by name) which can format it.
+func FormatSchedule(s Schedule) (string, error) {
+	scheduleParsersLock.Lock()
+	defer scheduleParsersLock.Unlock()
+
+	names := make([]string, 0, len(scheduleParsers))
+	for name := range scheduleParsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f, ok := scheduleParsers[name].(ScheduleFormatter)
+		if !ok {
+			continue
+		}
+		if args, ok := f.FormatSchedule(s); ok {
+			if len(args) == 0 {
+				return name, nil
+			}
+			return fmt.Sprintf("%s(%s)", name, strings.Join(args, " ")), nil
+		}
+	}
+	return "", fmt.Errorf("no schedule formatter registered for %T", s)
+}
+
+func (f *jsonSchedule) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.ScalarNode {
+		return nodeError(value, "invalid schedule")
+	}
+	schedule, err := parseSchedule(value.Value, GetScheduleParser)
+	if err != nil {
+		return nodeError(value, "%v", err)
+	}
+	*f = jsonSchedule{schedule}
+	return nil
+}
+
+// parseSchedule parses a schedule from text, such as Weekly(Thursday), using the schedule parser found for its name.
+func parseSchedule(s string, find func(name string) (ScheduleParser, bool)) (Schedule, error) {
 	matches := jsonScheduleRegex.FindStringSubmatch(s)
 	if len(matches) < 2 {
-		return fmt.Errorf("invalid schedule: %s", s)
+		return nil, fmt.Errorf("invalid schedule: %s", s)
 	}
 
 	var args []string
@@ -213,16 +960,22 @@ func (f *jsonSchedule) UnmarshalYAML(unmarshal func(interface{}) error) error {
 		args = strings.Fields(strings.Trim(matches[2], "()"))
 	}
 
-	parser, ok := GetScheduleParser(matches[1])
+	parser, ok := find(matches[1])
 	if !ok {
-		return fmt.Errorf("no schedule parser registered for name: %s", matches[1])
+		return nil, fmt.Errorf("no schedule parser registered for name: %s", matches[1])
 	}
 
 	schedule, err := parser.ParseSchedule(args)
 	if err != nil {
-		return fmt.Errorf("error parsing schedule: %v", err)
+		return nil, fmt.Errorf("error parsing schedule: %v", err)
 	}
+	return schedule, nil
+}
 
-	*f = jsonSchedule{schedule}
-	return nil
+func (f jsonSchedule) MarshalYAML() (interface{}, error) {
+	return FormatSchedule(f.parsed)
+}
+
+func (f jsonSchedule) IsZero() bool {
+	return f.parsed == nil
 }
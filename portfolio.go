@@ -1,6 +1,7 @@
 package munn
 
 import (
+	"math"
 	"time"
 )
 
@@ -10,8 +11,21 @@ type Portfolio struct {
 	Accounts          []*Account
 	Transactions      []*Transaction
 	ManualAdjustments []*ManualAdjustment
+	Pensions          []*Pension
+	VestingGrants     []*VestingGrant
+	Rebalances        []*Rebalance
 	RetirementPlan    *RetirementPlan
+	RMDStartAge       int
+	RMDTable          map[int]float32
+	Taxes             *Taxes
 	Debug             bool
+	start             time.Time
+	ledgerStart       time.Time
+	end               time.Time
+	ledger            []LedgerEntry
+	capitalGains      []CapitalGainsRecord
+	files             []string
+	source            source
 }
 
 // TotalBalance gets the current total balance for all accounts.
@@ -46,6 +60,25 @@ func (p *RetirementPlan) BalanceNeeded(t time.Time) float32 {
 	return float32(diffYear) * p.YearlyExpenses
 }
 
+// BalanceNeededToRetire is the balance needed to retire at a given date, counting pension income against the retirement plan's yearly expenses.
+func (p *Portfolio) BalanceNeededToRetire(t time.Time) float32 {
+	if len(p.Pensions) == 0 {
+		return p.RetirementPlan.BalanceNeeded(t)
+	}
+
+	var b float32
+	for year := t.Year(); year < p.RetirementPlan.DeathDate.Year(); year++ {
+		expenses := p.RetirementPlan.YearlyExpenses
+		for _, pen := range p.Pensions {
+			expenses -= pen.YearlyIncome(year)
+		}
+		if expenses > 0 {
+			b += expenses
+		}
+	}
+	return b
+}
+
 // NewAccount adds a new account to the portfolio.
 func (p *Portfolio) NewAccount(name string) *Account {
 	a := &Account{
@@ -59,7 +92,7 @@ func (p *Portfolio) NewAccount(name string) *Account {
 
 // NewManualAdjustment adds a new manual adjustment to the portfolio.
 // It should be used to set the initial balance for an account or to log significant intended changes in the value of an account.
-func (p *Portfolio) NewManualAdjustment(acc *Account, t time.Time, balance float32) {
+func (p *Portfolio) NewManualAdjustment(acc *Account, t time.Time, balance float32) *ManualAdjustment {
 	m := &ManualAdjustment{
 		Portfolio: p,
 		Account:   acc,
@@ -77,6 +110,7 @@ func (p *Portfolio) NewManualAdjustment(acc *Account, t time.Time, balance float
 	newArr[i] = m
 
 	p.ManualAdjustments = newArr
+	return m
 }
 
 // NewTransaction adds a new transaction to the portfolio.
@@ -96,11 +130,13 @@ func (p *Portfolio) NewTransaction(from []*Account, to *Account, desc string, s
 }
 
 // ManualAdjustment is a single manual adjustment made on an account.
+// If the account tracks cost basis, Basis is the cost basis of the new balance (the whole balance if nil).
 type ManualAdjustment struct {
 	Portfolio *Portfolio
 	Account   *Account
 	Time      time.Time
 	Balance   float32
+	Basis     *float32
 	applied   bool
 }
 
@@ -120,7 +156,11 @@ func (a *ManualAdjustment) Apply(now time.Time) bool {
 		a.Balance,
 		diff,
 	)
-	a.Account.Balance = a.Balance
+	basis := a.Balance
+	if a.Basis != nil {
+		basis = *a.Basis
+	}
+	a.Account.setBalance(now, a.Balance, basis)
 	return true
 }
 
@@ -128,15 +168,33 @@ func (a *ManualAdjustment) Apply(now time.Time) bool {
 // It may have a schedule to repeat the transaction on some interval.
 // If FromAccount or ToAccount is nil, this transaction represents money in/out of the portfolio (payments, income, etc.).
 // Otherwise it is a transfer between two accounts in the portfolio.
+// A taxable transaction counts as taxable income, and a deductible transaction is deducted from taxable income.
+// An employer match (see NewEmployerMatch) has MatchOf set to the transaction it matches.
+// The amount grows by AnnualGrowth every year after the projection starts.
+// A transaction with a Salary and a SalaryRate moves that share of the salary transaction's amount instead.
 type Transaction struct {
 	Description  string
+	Category     string
+	SubCategory  string
 	Portfolio    *Portfolio
 	Schedule     Schedule
 	FromAccounts []*Account
 	ToAccount    *Account
 	Amount       float32
+	AnnualGrowth float32
+	Salary       *Transaction
+	SalaryRate   float32
 	Start        *time.Time
 	Stop         *time.Time
+	Taxable      bool
+	Deductible   bool
+	MatchOf      *Transaction
+	MatchRate    float32
+	MatchLimit   float32
+	grant        *VestingGrant
+	withheld     float32
+	lastApplied  time.Time
+	lastAmount   float32
 }
 
 // Apply the transaction.
@@ -151,54 +209,161 @@ func (t *Transaction) Apply(now time.Time) bool {
 		return false
 	}
 
-	// Don't allow transferring money we don't have - still allow expenses (no "to" account)
-	if len(t.FromAccounts) > 0 && t.ToAccount != nil {
-		amt := t.Amount
-		for _, a := range t.FromAccounts {
-			if a.Balance < amt {
-				// Keep zeroing out accounts in order until we find one with a remaining balance
-				amt -= a.Balance
-				a.Balance = 0
-				continue
-			}
-			if amt <= a.Balance {
-				t.ToAccount.Balance += amt
-				a.Balance -= amt
-			}
-			break
+	amt := t.AmountIn(now.Year())
+	if t.MatchOf != nil {
+		amt = t.matchAmount(now.Year())
+	} else if t.ToAccount != nil {
+		full := amt
+		amt = t.ToAccount.contributionRoom(now, amt)
+		if amt < full {
+			t.Portfolio.logDebug("%s, Contribution limit reached for account %s, limited transaction %s to %.2f\n", now.Format("2006-01-02"), t.ToAccount.Name, t.Description, amt)
+		}
+	}
+
+	entry := LedgerEntry{
+		Time:        now,
+		Description: t.Description,
+		Category:    t.Category,
+		SubCategory: t.SubCategory,
+		To:          t.ToAccount,
+		Amount:      amt,
+		transaction: t,
+	}
+	if len(t.FromAccounts) > 0 {
+		before := make([]float32, len(t.FromAccounts))
+		for i, a := range t.FromAccounts {
+			before[i] = a.Balance
 		}
-	} else if len(t.FromAccounts) > 0 {
-		// Represents an expense (money "out of" the portfolio)
-		amt := t.Amount
-		for _, a := range t.FromAccounts {
-			if a.Balance < amt {
-				// Keep zeroing out accounts in order until we find one with a remaining balance
-				amt -= a.Balance
-				a.Balance = 0
-				continue
+
+		// Don't allow taking money we don't have
+		amt = takeInOrder(now, t.FromAccounts, t.ToAccount, amt)
+
+		// Only the money from the account which had enough goes on, so what was taken from the others is spent
+		passed := -1
+		for i, a := range t.FromAccounts {
+			if amt > 0 && a.Balance != before[i] {
+				passed = i
 			}
-			if a.Balance >= amt {
-				a.Balance -= amt
+		}
+		for i, a := range t.FromAccounts {
+			entry.From, entry.To = a, nil
+			if i == passed {
+				entry.To = t.ToAccount
 			}
-			break
+			entry.Amount = before[i] - a.Balance
+			t.Portfolio.record(entry)
 		}
-	} else if t.ToAccount != nil {
-		// Represents income (money "into" the portfolio)
-		t.ToAccount.Balance += t.Amount
+	} else {
+		t.Portfolio.record(entry)
+	}
+	if t.ToAccount != nil {
+		t.ToAccount.deposit(now, amt)
+		if t.MatchOf == nil {
+			t.ToAccount.contribute(now, amt)
+		}
+	}
+	t.lastApplied = now
+	t.lastAmount = amt
+
+	// Label each vest in the projection
+	if t.grant != nil {
+		t.ToAccount.events = append(t.ToAccount.events, t.Description)
+	}
+
+	if t.Taxable {
+		// Money withheld for taxes is still income, and counts toward the year's tax
+		t.Portfolio.addTaxableIncome(amt + t.withheld)
+		t.Portfolio.addTaxWithheld(t.withheld)
+	}
+	if t.Deductible {
+		t.Portfolio.addTaxDeduction(amt)
 	}
 
 	t.Portfolio.logDebug("%s, Applied transaction %s\n", now.Format("2006-01-02"), t.Description)
 	return true
 }
 
+// AmountIn gets the amount of the transaction in a year, after growing by AnnualGrowth every year after the projection starts.
+// A share of a salary is that share of the salary's amount in the year.
+func (t *Transaction) AmountIn(year int) float32 {
+	if t.Salary != nil && t.SalaryRate != 0 {
+		return t.Salary.AmountIn(year) * t.SalaryRate
+	}
+	years := year - t.Portfolio.start.Year()
+	if t.AnnualGrowth == 0 || years <= 0 {
+		return t.Amount
+	}
+	return t.Amount * float32(math.Pow(float64(1+t.AnnualGrowth), float64(years)))
+}
+
+// takeInOrder takes a transaction's amount out of its accounts in order, and returns how much goes on to the given account.
+// Each account without enough money is emptied (even if it was negative) and the rest is taken from the next account,
+// but only what is taken from the account which has enough goes on, so nothing does if none of them has enough.
+func takeInOrder(now time.Time, from []*Account, to *Account, amt float32) float32 {
+	for _, a := range from {
+		if a.Balance < amt {
+			// Keep zeroing out accounts in order until we find one with a remaining balance
+			amt -= a.Balance
+			withdraw(now, []*Account{a}, to, a.Balance)
+			if a.Balance < 0 {
+				a.setBalance(now, 0, 0)
+			}
+			continue
+		}
+		return withdraw(now, []*Account{a}, to, amt)
+	}
+	return 0
+}
+
+// withdraw takes up to amt out of the accounts in order, and returns how much was taken.
+// The money is going to the given account, or out of the portfolio if it is nil.
+func withdraw(now time.Time, from []*Account, to *Account, amt float32) float32 {
+	var taken float32
+	for _, a := range from {
+		if amt <= 0 {
+			break
+		}
+		x := amt
+		if a.Balance < x {
+			// Keep zeroing out accounts in order until we find one with a remaining balance
+			x = a.Balance
+		}
+		if x <= 0 {
+			continue
+		}
+		a.sell(now, x)
+		amt -= x
+		taken += x
+		a.Portfolio.addWithdrawal(a, to, x)
+	}
+	return taken
+}
+
 // Account is a named account with a balance.
 // An account may also have an annual interest rate which is applied monthly.
+// Accounts may belong to a group and have tags, which projection records can be aggregated by.
+// Deposits from transactions are cut off once they reach the account's annual contribution limit (no limit if 0), which resets every January.
+// An account with a cost basis method tracks the basis of each deposit, and realizes capital gains when money is taken out.
+// A tax-deferred account with an owner birth date is forced to take required minimum distributions, which go to RMDAccount or out of the portfolio.
 type Account struct {
-	Name               string
-	Portfolio          *Portfolio
-	Balance            float32
-	AnnualInterestRate float32
-	interestSchedule   Schedule
+	Name                    string
+	Group                   string
+	Tags                    []string
+	Portfolio               *Portfolio
+	Balance                 float32
+	AnnualInterestRate      float32
+	AnnualContributionLimit float32
+	ContributionLimitGrowth float32
+	TaxTreatment            TaxTreatment
+	CostBasisMethod         CostBasisMethod
+	OwnerBirthDate          *time.Time
+	RMDAccount              *Account
+	interestSchedule        Schedule
+	lastRMDYear             int
+	lots                    []*lot
+	contributionYear        int
+	contributed             float32
+	events                  []string
 }
 
 // GainInterest adds interest to the account.
@@ -213,7 +378,7 @@ func (a *Account) GainInterest(now time.Time) bool {
 	a.Portfolio.logDebug("%s, Account %s gained interest\n", now.Format("2006-01-02"), a.Name)
 
 	monthlyInterest := a.AnnualInterestRate / 12
-	a.Balance = a.Balance * (1 + monthlyInterest)
+	a.grow(1 + monthlyInterest)
 
 	return true
 }
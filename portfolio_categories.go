@@ -0,0 +1,181 @@
+package munn
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	chart "github.com/wcharczuk/go-chart"
+)
+
+// UncategorizedName is the category for expenses without a category.
+const UncategorizedName = "Uncategorized"
+
+// CategoryReport is how much was spent on each category of expenses during a projection.
+type CategoryReport struct {
+	Years      []int           `json:"years"`
+	Total      CategoryStats   `json:"total"`
+	Categories []CategoryStats `json:"categories"`
+}
+
+// CategoryStats is how much was spent on a category (or a sub-category) of expenses.
+// Yearly has the total spent in each of the report's years, so it shows how the cost changes as amounts grow.
+type CategoryStats struct {
+	Category       string          `json:"category"`
+	SubCategory    string          `json:"subCategory,omitempty"`
+	AverageMonthly float32         `json:"averageMonthly"`
+	AverageYearly  float32         `json:"averageYearly"`
+	Share          float32         `json:"share"`
+	Yearly         []float32       `json:"yearly"`
+	SubCategories  []CategoryStats `json:"subCategories,omitempty"`
+}
+
+// CategoryReport gets how much was spent on each category of expenses during the last projection.
+// Expenses are money leaving the portfolio, and categories are sorted from most to least expensive.
+// Like Stats, averages are over the months after the last manual adjustment.
+func (p *Portfolio) CategoryReport() CategoryReport {
+	var report CategoryReport
+	for y := p.ledgerStart.Year(); y <= p.end.Year(); y++ {
+		report.Years = append(report.Years, y)
+	}
+	months := float32(monthsBetween(p.ledgerStart, p.end))
+	if p.end.IsZero() {
+		months = 0
+	}
+
+	newStats := func(cat, sub string) *CategoryStats {
+		return &CategoryStats{
+			Category:    cat,
+			SubCategory: sub,
+			Yearly:      make([]float32, len(report.Years)),
+		}
+	}
+
+	total := newStats("Total", "")
+	categories := make(map[string]*CategoryStats)
+	subCategories := make(map[string]map[string]*CategoryStats)
+	for _, e := range p.ledger {
+		if e.Kind() != Expense {
+			continue
+		}
+		cat := e.Category
+		if cat == "" {
+			cat = UncategorizedName
+		}
+		c, ok := categories[cat]
+		if !ok {
+			c = newStats(cat, "")
+			categories[cat] = c
+			subCategories[cat] = make(map[string]*CategoryStats)
+		}
+
+		year := e.Time.Year() - p.ledgerStart.Year()
+		total.Yearly[year] += e.Amount
+		c.Yearly[year] += e.Amount
+		if e.SubCategory != "" {
+			sub, ok := subCategories[cat][e.SubCategory]
+			if !ok {
+				sub = newStats(cat, e.SubCategory)
+				subCategories[cat][e.SubCategory] = sub
+			}
+			sub.Yearly[year] += e.Amount
+		}
+	}
+
+	var totalSpent float32
+	for _, y := range total.Yearly {
+		totalSpent += y
+	}
+	finish := func(s *CategoryStats) {
+		var spent float32
+		for _, y := range s.Yearly {
+			spent += y
+		}
+		if months > 0 {
+			s.AverageMonthly = spent / months
+			s.AverageYearly = s.AverageMonthly * 12
+		}
+		if totalSpent > 0 {
+			s.Share = spent / totalSpent
+		}
+	}
+
+	finish(total)
+	report.Total = *total
+	for cat, c := range categories {
+		finish(c)
+		for _, sub := range subCategories[cat] {
+			finish(sub)
+			c.SubCategories = append(c.SubCategories, *sub)
+		}
+		sortCategoryStats(c.SubCategories)
+		report.Categories = append(report.Categories, *c)
+	}
+	sortCategoryStats(report.Categories)
+	return report
+}
+
+func sortCategoryStats(stats []CategoryStats) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Share != stats[j].Share {
+			return stats[i].Share > stats[j].Share
+		}
+		return stats[i].Category+stats[i].SubCategory < stats[j].Category+stats[j].SubCategory
+	})
+}
+
+func (r CategoryReport) String() string {
+	var o strings.Builder
+	o.WriteString("Category\tSub-category\tMonthly\tYearly\tShare")
+	for _, y := range r.Years {
+		fmt.Fprintf(&o, "\t%d", y)
+	}
+	writeStats := func(s CategoryStats) {
+		fmt.Fprintf(&o, "\n%s\t%s\t%.2f\t%.2f\t%.2f%%", s.Category, s.SubCategory, s.AverageMonthly, s.AverageYearly, s.Share*100)
+		for _, y := range s.Yearly {
+			fmt.Fprintf(&o, "\t%.2f", y)
+		}
+	}
+	for _, c := range r.Categories {
+		writeStats(c)
+		for _, sub := range c.SubCategories {
+			writeStats(sub)
+		}
+	}
+	writeStats(r.Total)
+	return o.String()
+}
+
+// PieChart generates a pie chart of each category's share of expenses.
+func (r CategoryReport) PieChart() chart.PieChart {
+	var values []chart.Value
+	for _, c := range r.Categories {
+		values = append(values, chart.Value{
+			Label: fmt.Sprintf("%s (%.0f%%)", c.Category, c.Share*100),
+			Value: float64(c.AverageMonthly),
+		})
+	}
+	return chart.PieChart{
+		Title:  "Expenses by Category",
+		Width:  1024,
+		Height: 1024,
+		Values: values,
+	}
+}
+
+// BarChart generates a bar chart of each category's average monthly cost.
+func (r CategoryReport) BarChart() chart.BarChart {
+	var bars []chart.Value
+	for _, c := range r.Categories {
+		bars = append(bars, chart.Value{
+			Label: c.Category,
+			Value: float64(c.AverageMonthly),
+		})
+	}
+	return chart.BarChart{
+		Title:    "Average Monthly Expenses by Category",
+		Height:   512,
+		BarWidth: 60,
+		Bars:     bars,
+	}
+}
@@ -8,31 +8,48 @@ import (
 	"golang.org/x/text/message"
 )
 
-// Chart generates a chart for the projection.
+// Chart generates a chart for the projection, with each account (or group) stacked on the ones before it.
 func (p Portfolio) Chart(recs []ProjectionRecord) chart.Chart {
+	return p.chart(recs, true)
+}
+
+// LineChart generates a chart for the projection with a line for each account (or group) on its own,
+// for groups which share accounts (like tags) and so can't be stacked.
+func (p Portfolio) LineChart(recs []ProjectionRecord) chart.Chart {
+	return p.chart(recs, false)
+}
+
+func (p Portfolio) chart(recs []ProjectionRecord, stacked bool) chart.Chart {
 	var sum float32
 	var lastTime time.Time
-	seriesMap := make(map[string]*chart.TimeSeries)
+	// Accounts with the same name have their own series
+	var keys []interface{}
+	seriesMap := make(map[interface{}]*chart.TimeSeries)
 	for _, rec := range recs {
 		if rec.Time != lastTime {
 			sum = 0
 		}
 		lastTime = rec.Time
-		s, ok := seriesMap[rec.AccountName]
+		s, ok := seriesMap[rec.key()]
 		if !ok {
 			s = &chart.TimeSeries{
 				Name: rec.AccountName,
 			}
-			seriesMap[rec.AccountName] = s
+			seriesMap[rec.key()] = s
+			keys = append(keys, rec.key())
 		}
 		sum += rec.Balance
+		if !stacked {
+			sum = rec.Balance
+		}
 		s.XValues = append(s.XValues, rec.Time)
 		s.YValues = append(s.YValues, float64(sum))
 	}
 
+	// Stack the series in reverse, so the first account (or group) is on the bottom
 	var series []chart.Series
-	for i := len(p.Accounts) - 1; i >= 0; i-- {
-		series = append(series, seriesMap[p.Accounts[i].Name])
+	for i := len(keys) - 1; i >= 0; i-- {
+		series = append(series, seriesMap[keys[i]])
 	}
 
 	graph := chart.Chart{
@@ -0,0 +1,100 @@
+package munn
+
+import (
+	"math"
+	"time"
+)
+
+// ContributionLimit gets the most that can be contributed to the account in a year.
+// The limit grows by ContributionLimitGrowth every year after the projection starts.
+// An account without a limit returns 0.
+func (a *Account) ContributionLimit(year int) float32 {
+	if a.AnnualContributionLimit == 0 {
+		return 0
+	}
+	years := year - a.Portfolio.start.Year()
+	if years < 0 {
+		years = 0
+	}
+	return a.AnnualContributionLimit * float32(math.Pow(float64(1+a.ContributionLimitGrowth), float64(years)))
+}
+
+// contributionRoom gets how much of amt can be contributed to the account without going over its limit for the year.
+func (a *Account) contributionRoom(now time.Time, amt float32) float32 {
+	limit := a.ContributionLimit(now.Year())
+	if limit == 0 {
+		return amt
+	}
+	if a.contributionYear != now.Year() {
+		a.contributionYear = now.Year()
+		a.contributed = 0
+	}
+	if room := limit - a.contributed; amt > room {
+		if room < 0 {
+			return 0
+		}
+		return room
+	}
+	return amt
+}
+
+// contribute counts money deposited by a transaction toward the account's limit for the year.
+func (a *Account) contribute(now time.Time, amt float32) {
+	if a.AnnualContributionLimit == 0 {
+		return
+	}
+	if a.contributionYear != now.Year() {
+		a.contributionYear = now.Year()
+		a.contributed = 0
+	}
+	a.contributed += amt
+}
+
+// NewEmployerMatch adds an employer match for the transaction to the portfolio.
+// Every time the transaction is applied, the match deposits rate times the amount contributed into the same account.
+// Only contributions up to upTo times the transaction's salary are matched (no limit if 0, or if the transaction has no salary),
+// so "50% up to 6% of salary" is a rate of 0.5 and upTo of 0.06.
+// Employer matches don't count toward the account's contribution limit.
+func (t *Transaction) NewEmployerMatch(rate, upTo float32) *Transaction {
+	amt := matchedAmount(t, t.Amount, rate, upTo, t.Portfolio.start.Year())
+	m := t.Portfolio.NewTransaction(nil, t.ToAccount, t.Description+" (employer match)", &matchSchedule{transaction: t}, nil, nil, amt)
+	m.Category = t.Category
+	m.SubCategory = t.SubCategory
+	m.MatchOf = t
+	m.MatchRate = rate
+	m.MatchLimit = upTo
+	return m
+}
+
+// matchAmount gets the amount of an employer match for the last contribution of the transaction it matches.
+func (t *Transaction) matchAmount(year int) float32 {
+	return matchedAmount(t.MatchOf, t.MatchOf.lastAmount, t.MatchRate, t.MatchLimit, year)
+}
+
+// matchedAmount gets the match for a contribution by a transaction, only matching up to upTo times its salary in the year.
+func matchedAmount(t *Transaction, contribution, rate, upTo float32, year int) float32 {
+	if t.Salary != nil && upTo > 0 {
+		if limit := t.Salary.AmountIn(year) * upTo; contribution > limit {
+			contribution = limit
+		}
+	}
+	return contribution * rate
+}
+
+// matchSchedule applies on the days the transaction it matches is applied.
+type matchSchedule struct {
+	transaction *Transaction
+	lastApplied time.Time
+}
+
+func (s *matchSchedule) ShouldApply(t time.Time) bool {
+	if !s.transaction.lastApplied.Equal(t) || s.lastApplied.Equal(t) {
+		return false
+	}
+	s.lastApplied = t
+	return true
+}
+
+func (s *matchSchedule) YearlyFactor() float32 {
+	return s.transaction.Schedule.YearlyFactor()
+}
@@ -0,0 +1,168 @@
+package munn
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CostBasisMethod is how an account tracks cost basis, and how withdrawals choose which lots to sell.
+type CostBasisMethod int
+
+const (
+	// NoCostBasis doesn't track cost basis.
+	NoCostBasis CostBasisMethod = iota
+	// ProportionalCostBasis sells the same fraction of every lot.
+	ProportionalCostBasis
+	// FIFOCostBasis sells the oldest lots first.
+	FIFOCostBasis
+)
+
+var costBasisMethodNames = map[CostBasisMethod]string{
+	NoCostBasis:           "none",
+	ProportionalCostBasis: "proportional",
+	FIFOCostBasis:         "fifo",
+}
+
+func (m CostBasisMethod) String() string {
+	return costBasisMethodNames[m]
+}
+
+// ParseCostBasisMethod parses a cost basis method from its name.
+func ParseCostBasisMethod(s string) (CostBasisMethod, error) {
+	for m, name := range costBasisMethodNames {
+		if strings.EqualFold(s, name) {
+			return m, nil
+		}
+	}
+	return NoCostBasis, fmt.Errorf("invalid cost basis method: %s", s)
+}
+
+// lot is money deposited into an account at one time, along with the growth it has gained since.
+type lot struct {
+	time  time.Time
+	basis float32
+	value float32
+}
+
+// CapitalGainsRecord is the gains realized in a year by selling from accounts that track cost basis.
+// Gains on lots held for more than a year are long-term.
+type CapitalGainsRecord struct {
+	Year      int
+	ShortTerm float32
+	LongTerm  float32
+}
+
+// CostBasis gets the total cost basis of the account.
+func (a *Account) CostBasis() float32 {
+	var b float32
+	for _, l := range a.lots {
+		b += l.basis
+	}
+	return b
+}
+
+// UnrealizedGains gets the gains in the account which haven't been sold yet.
+func (a *Account) UnrealizedGains() float32 {
+	if a.CostBasisMethod == NoCostBasis {
+		return 0
+	}
+	return a.Balance - a.CostBasis()
+}
+
+// deposit adds money to the account, as a new lot if the account tracks cost basis.
+func (a *Account) deposit(now time.Time, amt float32) {
+	a.Balance += amt
+	if a.CostBasisMethod != NoCostBasis && amt > 0 {
+		a.lots = append(a.lots, &lot{
+			time:  now,
+			basis: amt,
+			value: amt,
+		})
+	}
+}
+
+// setBalance sets the balance of the account, which replaces its lots with a single lot of the given basis.
+func (a *Account) setBalance(now time.Time, balance, basis float32) {
+	a.Balance = balance
+	a.lots = nil
+	if a.CostBasisMethod != NoCostBasis && balance > 0 {
+		a.lots = append(a.lots, &lot{
+			time:  now,
+			basis: basis,
+			value: balance,
+		})
+	}
+}
+
+// grow multiplies the balance of the account, which is unrealized gains for every lot.
+func (a *Account) grow(factor float32) {
+	a.Balance *= factor
+	for _, l := range a.lots {
+		l.value *= factor
+	}
+}
+
+// sell takes money out of the account, and realizes the gains of the lots it was taken from.
+func (a *Account) sell(now time.Time, amt float32) {
+	a.Balance -= amt
+	if len(a.lots) == 0 {
+		return
+	}
+
+	var total float32
+	for _, l := range a.lots {
+		total += l.value
+	}
+	if total <= 0 {
+		return
+	}
+
+	var remaining []*lot
+	for _, l := range a.lots {
+		var x float32
+		switch a.CostBasisMethod {
+		case FIFOCostBasis:
+			x = amt
+			if x > l.value {
+				x = l.value
+			}
+			amt -= x
+		default:
+			x = l.value * amt / total
+		}
+
+		if x > 0 {
+			basis := l.basis * x / l.value
+			long := now.After(l.time.AddDate(1, 0, 0))
+			a.Portfolio.realizeGain(now, x-basis, long)
+			l.basis -= basis
+			l.value -= x
+		}
+
+		if l.value > 0.005 {
+			remaining = append(remaining, l)
+		}
+	}
+	a.lots = remaining
+}
+
+// CapitalGains gets the gains realized in each year of the last projection.
+func (p *Portfolio) CapitalGains() []CapitalGainsRecord {
+	return p.capitalGains
+}
+
+// realizeGain records a realized gain, and counts short-term gains as taxable income.
+func (p *Portfolio) realizeGain(now time.Time, gain float32, long bool) {
+	year := now.Year()
+	if n := len(p.capitalGains); n == 0 || p.capitalGains[n-1].Year != year {
+		p.capitalGains = append(p.capitalGains, CapitalGainsRecord{Year: year})
+	}
+	rec := &p.capitalGains[len(p.capitalGains)-1]
+	if long {
+		rec.LongTerm += gain
+	} else {
+		rec.ShortTerm += gain
+		p.addTaxableIncome(gain)
+	}
+}
@@ -0,0 +1,237 @@
+package munn
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrGoalUnreachable is returned by GoalSeek when the goal is met at neither end of the search range.
+var ErrGoalUnreachable = errors.New("goal cannot be reached between the given min and max")
+
+// ErrGoalAlwaysMet is returned by GoalSeek when the goal is met at both ends of the search range, so there is no boundary to find.
+var ErrGoalAlwaysMet = errors.New("goal is already met at both the given min and max")
+
+// maxGoalSeekSteps caps how many times GoalSeek halves the search range, in case the tolerance is too small to ever reach.
+const maxGoalSeekSteps = 200
+
+// Goal is a target for a projection to reach.
+type Goal interface {
+	Met(p *Portfolio, recs []ProjectionRecord) bool
+}
+
+// BalanceGoal is met when the total balance on the given date is at least the given balance.
+func BalanceGoal(t time.Time, balance float32) Goal {
+	return &balanceGoal{
+		time:    t,
+		balance: balance,
+	}
+}
+
+type balanceGoal struct {
+	time    time.Time
+	balance float32
+}
+
+func (g *balanceGoal) Met(p *Portfolio, recs []ProjectionRecord) bool {
+	var total float32
+	var lastTime time.Time
+	for _, rec := range recs {
+		if rec.Time.After(g.time) {
+			break
+		}
+		if rec.Time != lastTime {
+			total = 0
+		}
+		lastTime = rec.Time
+		total += rec.Balance
+	}
+	return total >= g.balance
+}
+
+// RetireByGoal is met when the portfolio's retirement plan finds a retirement date on or before the given date.
+func RetireByGoal(t time.Time) Goal {
+	return &retireByGoal{
+		time: t,
+	}
+}
+
+type retireByGoal struct {
+	time time.Time
+}
+
+func (g *retireByGoal) Met(p *Portfolio, recs []ProjectionRecord) bool {
+	if p.RetirementPlan == nil {
+		return false
+	}
+	date, ok := p.RetirementPlan.RetireDate()
+	return ok && !date.After(g.time)
+}
+
+// NeverDepleteGoal is met when the total balance never drops to zero during the projection.
+func NeverDepleteGoal() Goal {
+	return &neverDepleteGoal{}
+}
+
+type neverDepleteGoal struct{}
+
+func (g *neverDepleteGoal) Met(p *Portfolio, recs []ProjectionRecord) bool {
+	var total float32
+	for i, rec := range recs {
+		total += rec.Balance
+		if i == len(recs)-1 || recs[i+1].Time != rec.Time {
+			if total <= 0 {
+				return false
+			}
+			total = 0
+		}
+	}
+	return true
+}
+
+// GoalVariable is a value in a portfolio which can be solved for with GoalSeek.
+type GoalVariable interface {
+	Set(p *Portfolio, v float64) error
+	Format(v float64) string
+}
+
+// TransactionAmount solves for the amount of the transaction with the given description.
+func TransactionAmount(desc string) GoalVariable {
+	return &transactionAmount{
+		desc: desc,
+	}
+}
+
+type transactionAmount struct {
+	desc string
+}
+
+func (v *transactionAmount) Set(p *Portfolio, x float64) error {
+	t, err := p.findTransaction(v.desc)
+	if err != nil {
+		return err
+	}
+	t.Amount = float32(x)
+	return nil
+}
+
+func (v *transactionAmount) Format(x float64) string {
+	return fmt.Sprintf("%.2f", x)
+}
+
+// RetirementExpenses solves for the yearly expenses of the portfolio's retirement plan.
+func RetirementExpenses() GoalVariable {
+	return &retirementExpenses{}
+}
+
+type retirementExpenses struct{}
+
+func (v *retirementExpenses) Set(p *Portfolio, x float64) error {
+	if p.RetirementPlan == nil {
+		return fmt.Errorf("portfolio has no retirement plan")
+	}
+	p.RetirementPlan.YearlyExpenses = float32(x)
+	return nil
+}
+
+func (v *retirementExpenses) Format(x float64) string {
+	return fmt.Sprintf("%.2f", x)
+}
+
+// TransactionStart solves for the start date of the transaction with the given description.
+// The value being solved for is the number of days after the given base date.
+func TransactionStart(desc string, base time.Time) GoalVariable {
+	return &transactionStart{
+		desc: desc,
+		base: base,
+	}
+}
+
+type transactionStart struct {
+	desc string
+	base time.Time
+}
+
+func (v *transactionStart) Set(p *Portfolio, x float64) error {
+	t, err := p.findTransaction(v.desc)
+	if err != nil {
+		return err
+	}
+	start := v.date(x)
+	t.Start = &start
+	return nil
+}
+
+func (v *transactionStart) Format(x float64) string {
+	return v.date(x).Format("2006-01-02")
+}
+
+func (v *transactionStart) date(x float64) time.Time {
+	return v.base.AddDate(0, 0, int(math.Round(x)))
+}
+
+func (p *Portfolio) findTransaction(desc string) (*Transaction, error) {
+	for _, t := range p.Transactions {
+		if t.Description == desc {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("no transaction with description: %s", desc)
+}
+
+// GoalSeek finds the value for a variable, between min and max, at the boundary of where the goal is met.
+// Each step builds a fresh portfolio and projects it for the given number of years, so build must not reuse any state between calls.
+// The returned value is always on the side of the boundary where the goal is met.
+// The tolerance must be more than 0.
+func GoalSeek(build func() (*Portfolio, error), years int, goal Goal, v GoalVariable, min, max, tolerance float64) (float64, error) {
+	if tolerance <= 0 {
+		return 0, fmt.Errorf("tolerance must be more than 0: %v", tolerance)
+	}
+
+	met := func(x float64) (bool, error) {
+		p, err := build()
+		if err != nil {
+			return false, err
+		}
+		if err := v.Set(p, x); err != nil {
+			return false, err
+		}
+		recs := p.Project(years)
+		return goal.Met(p, recs), nil
+	}
+
+	minMet, err := met(min)
+	if err != nil {
+		return 0, err
+	}
+	maxMet, err := met(max)
+	if err != nil {
+		return 0, err
+	}
+	if minMet && maxMet {
+		return 0, ErrGoalAlwaysMet
+	}
+	if !minMet && !maxMet {
+		return 0, ErrGoalUnreachable
+	}
+
+	// Keep "good" on the side where the goal is met, and narrow the range until it is within the tolerance
+	good, bad := min, max
+	if maxMet {
+		good, bad = max, min
+	}
+	for i := 0; i < maxGoalSeekSteps && math.Abs(good-bad) > tolerance; i++ {
+		mid := (good + bad) / 2
+		ok, err := met(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			good = mid
+		} else {
+			bad = mid
+		}
+	}
+	return good, nil
+}
@@ -0,0 +1,92 @@
+package munn
+
+import (
+	"fmt"
+)
+
+// UngroupedName is the group records are aggregated under for accounts without a group or tags.
+const UngroupedName = "Other"
+
+// Grouping chooses the groups an account's balance is aggregated into.
+// A nil grouping keeps every account on its own.
+type Grouping func(a *Account) []string
+
+// ByAccount keeps every account on its own, so GroupRecords leaves records as they are, even for accounts with the same name.
+var ByAccount Grouping
+
+// ByGroup groups accounts by their group.
+func ByGroup(a *Account) []string {
+	if a.Group == "" {
+		return []string{UngroupedName}
+	}
+	return []string{a.Group}
+}
+
+// ByTag groups accounts by each of their tags, so an account with several tags is counted in each of them.
+func ByTag(a *Account) []string {
+	if len(a.Tags) == 0 {
+		return []string{UngroupedName}
+	}
+	return a.Tags
+}
+
+// ParseGrouping gets a grouping by name: "account", "group" or "tag".
+func ParseGrouping(s string) (Grouping, error) {
+	switch s {
+	case "", "account":
+		return ByAccount, nil
+	case "group":
+		return ByGroup, nil
+	case "tag":
+		return ByTag, nil
+	default:
+		return nil, fmt.Errorf("invalid grouping: %s", s)
+	}
+}
+
+// GroupRecords aggregates projection records from Project into groups of accounts.
+// Groups are in the order they are first found in the portfolio's accounts.
+func (p *Portfolio) GroupRecords(recs []ProjectionRecord, grouping Grouping) []ProjectionRecord {
+	if grouping == nil {
+		return recs
+	}
+
+	var names []string
+	groups := make(map[*Account][]string)
+	seen := make(map[string]bool)
+	for _, a := range p.Accounts {
+		groups[a] = grouping(a)
+		for _, g := range groups[a] {
+			if !seen[g] {
+				seen[g] = true
+				names = append(names, g)
+			}
+		}
+	}
+
+	var grouped []ProjectionRecord
+	for i := 0; i < len(recs); {
+		t := recs[i].Time
+		byGroup := make(map[string]*ProjectionRecord)
+		for ; i < len(recs) && recs[i].Time.Equal(t); i++ {
+			for _, g := range groups[recs[i].account] {
+				rec, ok := byGroup[g]
+				if !ok {
+					rec = &ProjectionRecord{
+						Time:        t,
+						AccountName: g,
+					}
+					byGroup[g] = rec
+				}
+				rec.Balance += recs[i].Balance
+				rec.Events = append(rec.Events, recs[i].Events...)
+			}
+		}
+		for _, g := range names {
+			if rec, ok := byGroup[g]; ok {
+				grouped = append(grouped, *rec)
+			}
+		}
+	}
+	return grouped
+}
@@ -0,0 +1,78 @@
+package munn
+
+import (
+	"time"
+)
+
+// LedgerEntry is money moved during a projection.
+// From is nil for money coming into the portfolio (income), and To is nil for money leaving it (expenses).
+// A transaction taking money out of several accounts has an entry for each account,
+// and the money taken from accounts without enough (which doesn't go on) is an expense.
+type LedgerEntry struct {
+	Time        time.Time
+	Description string
+	Category    string
+	SubCategory string
+	From        *Account
+	To          *Account
+	Amount      float32
+	transaction *Transaction
+}
+
+// FlowKind is whether money moved into, out of, or within the portfolio.
+type FlowKind int
+
+const (
+	// Income is money coming into the portfolio.
+	Income FlowKind = iota
+	// Expense is money leaving the portfolio.
+	Expense
+	// Transfer is money moving between accounts in the portfolio.
+	Transfer
+)
+
+func (k FlowKind) String() string {
+	switch k {
+	case Income:
+		return "income"
+	case Expense:
+		return "expense"
+	default:
+		return "transfer"
+	}
+}
+
+// Kind gets whether the entry is income, an expense or a transfer.
+func (e LedgerEntry) Kind() FlowKind {
+	switch {
+	case e.From == nil:
+		return Income
+	case e.To == nil:
+		return Expense
+	default:
+		return Transfer
+	}
+}
+
+// Ledger gets the money moved during the last projection.
+func (p *Portfolio) Ledger() []LedgerEntry {
+	return p.ledger
+}
+
+// TransactionLedger gets the money a transaction moved during the last projection.
+func (p *Portfolio) TransactionLedger(t *Transaction) []LedgerEntry {
+	var entries []LedgerEntry
+	for _, e := range p.ledger {
+		if e.transaction == t {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+func (p *Portfolio) record(e LedgerEntry) {
+	if e.Amount == 0 || (e.From == nil && e.To == nil) {
+		return
+	}
+	p.ledger = append(p.ledger, e)
+}
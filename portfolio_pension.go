@@ -0,0 +1,119 @@
+package munn
+
+import (
+	"math"
+	"time"
+)
+
+// DefaultFullRetirementAge is the full retirement age used for a pension when none is given.
+const DefaultFullRetirementAge = 67
+
+// Pension is a government pension or social security benefit paid monthly into an account once it is claimed.
+// Benefit is the monthly benefit at the full retirement age, in dollars as of the claim date.
+// Claiming early reduces the benefit and claiming late (up to age 70) increases it.
+// After the claim year the benefit grows every January by the cost of living adjustment (COLA).
+// A taxable pension counts as taxable income.
+type Pension struct {
+	Description       string
+	Portfolio         *Portfolio
+	ToAccount         *Account
+	Benefit           float32
+	BirthDate         time.Time
+	ClaimDate         time.Time
+	FullRetirementAge int
+	COLA              float32
+	Taxable           bool
+	schedule          Schedule
+}
+
+// NewPension adds a new pension to the portfolio.
+func (p *Portfolio) NewPension(to *Account, desc string, benefit float32, birth, claim time.Time) *Pension {
+	pen := &Pension{
+		Description:       desc,
+		Portfolio:         p,
+		ToAccount:         to,
+		Benefit:           benefit,
+		BirthDate:         birth,
+		ClaimDate:         claim,
+		FullRetirementAge: DefaultFullRetirementAge,
+	}
+	p.Pensions = append(p.Pensions, pen)
+	return pen
+}
+
+// ClaimAdjustment is the factor the benefit is multiplied by for claiming before or after the full retirement age.
+// Each month early reduces the benefit by 5/9 of 1% for the first 36 months and 5/12 of 1% after that.
+// Each month late increases the benefit by 2/3 of 1%, up to age 70.
+func (pen *Pension) ClaimAdjustment() float32 {
+	fra := pen.BirthDate.AddDate(pen.FullRetirementAge, 0, 0)
+	months := monthsBetween(fra, pen.ClaimDate)
+	if months < 0 {
+		early := float32(-months)
+		if early <= 36 {
+			return 1 - early*(5.0/9/100)
+		}
+		return 1 - 36*(5.0/9/100) - (early-36)*(5.0/12/100)
+	}
+	maxLate := monthsBetween(fra, pen.BirthDate.AddDate(70, 0, 0))
+	if months > maxLate {
+		months = maxLate
+	}
+	return 1 + float32(months)*(2.0/3/100)
+}
+
+// MonthlyBenefit is the benefit paid for a month in the given year.
+func (pen *Pension) MonthlyBenefit(year int) float32 {
+	claimYear := pen.ClaimDate.Year()
+	if year < claimYear {
+		return 0
+	}
+	cola := math.Pow(float64(1+pen.COLA), float64(year-claimYear))
+	return pen.Benefit * pen.ClaimAdjustment() * float32(cola)
+}
+
+// YearlyIncome is the total benefit paid in the given year.
+func (pen *Pension) YearlyIncome(year int) float32 {
+	claimYear, claimMonth, _ := pen.ClaimDate.Date()
+	if year < claimYear {
+		return 0
+	}
+	months := 12
+	if year == claimYear {
+		months = 12 - int(claimMonth) + 1
+	}
+	return float32(months) * pen.MonthlyBenefit(year)
+}
+
+// Apply the pension payment.
+func (pen *Pension) Apply(now time.Time) bool {
+	if now.Before(pen.ClaimDate) {
+		return false
+	}
+	if pen.schedule == nil {
+		pen.schedule = Monthly(pen.ClaimDate.Day())
+	}
+	if !pen.schedule.ShouldApply(now) {
+		return false
+	}
+
+	amt := pen.MonthlyBenefit(now.Year())
+	pen.ToAccount.deposit(now, amt)
+	pen.Portfolio.record(LedgerEntry{
+		Time:        now,
+		Description: pen.Description,
+		To:          pen.ToAccount,
+		Amount:      amt,
+	})
+	if pen.Taxable {
+		pen.Portfolio.addTaxableIncome(amt)
+	}
+
+	pen.Portfolio.logDebug("%s, Applied pension %s of %.2f\n", now.Format("2006-01-02"), pen.Description, amt)
+	return true
+}
+
+func monthsBetween(from, to time.Time) int {
+	fromYear, fromMonth, _ := from.Date()
+	toYear, toMonth, _ := to.Date()
+	return (toYear-fromYear)*12 + int(toMonth-fromMonth)
+}
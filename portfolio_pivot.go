@@ -0,0 +1,51 @@
+package munn
+
+import (
+	"time"
+)
+
+// ProjectionTable is a projection with a row for each time and a column for each account (or group).
+type ProjectionTable struct {
+	Columns []string
+	Times   []time.Time
+	// Balances has a row for each time, with the balance of each column.
+	Balances [][]float32
+	// Totals is the total of each row.
+	Totals []float32
+}
+
+// PivotRecords turns projection records into a table, with the columns in the order they are first found.
+// Accounts with the same name still have their own columns.
+// The records should be in time order, like those from Project.
+// A column without a record at a time keeps its balance from the time before.
+func PivotRecords(recs []ProjectionRecord) ProjectionTable {
+	var t ProjectionTable
+	index := make(map[interface{}]int)
+	for _, r := range recs {
+		if _, ok := index[r.key()]; !ok {
+			index[r.key()] = len(t.Columns)
+			t.Columns = append(t.Columns, r.AccountName)
+		}
+	}
+
+	var row []float32
+	for i, r := range recs {
+		if i == 0 || !r.Time.Equal(recs[i-1].Time) {
+			row = make([]float32, len(t.Columns))
+			if n := len(t.Balances); n > 0 {
+				copy(row, t.Balances[n-1])
+			}
+			t.Times = append(t.Times, r.Time)
+			t.Balances = append(t.Balances, row)
+		}
+		row[index[r.key()]] = r.Balance
+	}
+
+	t.Totals = make([]float32, len(t.Balances))
+	for i, row := range t.Balances {
+		for _, b := range row {
+			t.Totals[i] += b
+		}
+	}
+	return t
+}
@@ -6,10 +6,22 @@ import (
 )
 
 // ProjectionRecord is a record in a projection.
+// Events labels notable changes to the account at the time, such as vests from a vesting grant.
+// Records from Project also know their account, since accounts can share a name.
 type ProjectionRecord struct {
-	Time        time.Time
-	AccountName string
-	Balance     float32
+	Time        time.Time `json:"time"`
+	AccountName string    `json:"account"`
+	Balance     float32   `json:"balance"`
+	Events      []string  `json:"events,omitempty"`
+	account     *Account
+}
+
+// key tells apart the records of different accounts: by the account if the record has one, or else by name (such as for a group).
+func (r ProjectionRecord) key() interface{} {
+	if r.account != nil {
+		return r.account
+	}
+	return r.AccountName
 }
 
 // Project a portfolio's balances for a period of time.
@@ -27,6 +39,9 @@ func (p *Portfolio) Project(years int) []ProjectionRecord {
 
 	from := manTimes[0]
 	to := from.AddDate(years, 0, 0)
+	p.start = from
+	p.end = to
+	p.ledger = nil
 
 	var recs []ProjectionRecord
 	now := from
@@ -43,11 +58,14 @@ func (p *Portfolio) Project(years int) []ProjectionRecord {
 				Time:        now,
 				AccountName: acc.Name,
 				Balance:     acc.Balance,
+				Events:      acc.events,
+				account:     acc,
 			})
+			acc.events = nil
 		}
 
 		if p.RetirementPlan != nil && p.RetirementPlan.retireDate == nil {
-			if p.TotalBalance() > p.RetirementPlan.BalanceNeeded(now) {
+			if p.TotalBalance() > p.BalanceNeededToRetire(now) {
 				rd := now
 				p.RetirementPlan.retireDate = &rd
 			}
@@ -74,25 +92,59 @@ func (p *Portfolio) Project(years int) []ProjectionRecord {
 		}
 	}
 
+	// Money moved before the last manual adjustment is already in its balances, so only the projected period is in the ledger,
+	// the capital gains and the taxes
+	p.ledger = nil
+	p.ledgerStart = now
+	p.capitalGains = nil
+	if p.Taxes != nil {
+		p.Taxes.reset()
+		p.settleTaxes(now)
+	}
+
 	for ; !now.After(to); now = now.AddDate(0, 0, 1) {
 		var changed bool
 
+		if p.settleTaxes(now) {
+			changed = true
+		}
+
 		for _, acc := range p.Accounts {
 			if acc.GainInterest(now) {
 				changed = true
 			}
 		}
 
+		for _, acc := range p.Accounts {
+			if acc.TakeRMD(now) {
+				changed = true
+			}
+		}
+
 		for _, trans := range p.Transactions {
 			if trans.Apply(now) {
 				changed = true
 			}
 		}
 
+		for _, pen := range p.Pensions {
+			if pen.Apply(now) {
+				changed = true
+			}
+		}
+
+		for _, r := range p.Rebalances {
+			if r.Apply(now) {
+				changed = true
+			}
+		}
+
 		if changed {
 			recordAccounts()
 		}
 	}
+
+	p.finishTaxes()
 	return recs
 }
 
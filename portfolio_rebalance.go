@@ -0,0 +1,90 @@
+package munn
+
+import (
+	"time"
+)
+
+// Rebalance moves money between a group of accounts on a schedule, to keep each account at a target percentage of the group's total balance.
+// Accounts are only rebalanced once an account has drifted from its target by more than the tolerance.
+type Rebalance struct {
+	Description string
+	Portfolio   *Portfolio
+	Schedule    Schedule
+	Targets     []RebalanceTarget
+	Tolerance   float32
+}
+
+// RebalanceTarget is the target percentage (from 0 to 1) of a group's total balance for an account.
+type RebalanceTarget struct {
+	Account *Account
+	Percent float32
+}
+
+// NewRebalance adds a new rebalancing rule to the portfolio.
+func (p *Portfolio) NewRebalance(desc string, s Schedule, targets []RebalanceTarget, tolerance float32) *Rebalance {
+	r := &Rebalance{
+		Description: desc,
+		Portfolio:   p,
+		Schedule:    s,
+		Targets:     targets,
+		Tolerance:   tolerance,
+	}
+	p.Rebalances = append(p.Rebalances, r)
+	return r
+}
+
+// Apply the rebalance.
+func (r *Rebalance) Apply(now time.Time) bool {
+	if !r.Schedule.ShouldApply(now) {
+		return false
+	}
+
+	var total float32
+	for _, t := range r.Targets {
+		total += t.Account.Balance
+	}
+	if total <= 0 {
+		return false
+	}
+
+	var drifted bool
+	diffs := make([]float32, len(r.Targets))
+	for i, t := range r.Targets {
+		diffs[i] = t.Account.Balance - t.Percent*total
+		if drift := diffs[i] / total; drift > r.Tolerance || -drift > r.Tolerance {
+			drifted = true
+		}
+	}
+	if !drifted {
+		return false
+	}
+
+	// Move money from the accounts over their targets into the accounts under their targets
+	for i, from := range r.Targets {
+		for j, to := range r.Targets {
+			if diffs[i] <= 0 {
+				break
+			}
+			if diffs[j] >= 0 {
+				continue
+			}
+			amt := diffs[i]
+			if -diffs[j] < amt {
+				amt = -diffs[j]
+			}
+			amt = withdraw(now, []*Account{from.Account}, to.Account, amt)
+			to.Account.deposit(now, amt)
+			r.Portfolio.record(LedgerEntry{
+				Time:        now,
+				Description: r.Description,
+				From:        from.Account,
+				To:          to.Account,
+				Amount:      amt,
+			})
+			diffs[i] -= amt
+			diffs[j] += amt
+			r.Portfolio.logDebug("%s, Rebalance %s moved %.2f from %s to %s\n", now.Format("2006-01-02"), r.Description, amt, from.Account.Name, to.Account.Name)
+		}
+	}
+	return true
+}
@@ -0,0 +1,216 @@
+package munn
+
+import (
+	"fmt"
+	"time"
+)
+
+// Interval is how long each record covers when resampling a projection.
+type Interval int
+
+const (
+	// IntervalDay is a record for each day.
+	IntervalDay Interval = iota
+	// IntervalWeek is a record for each week, starting on Sunday.
+	IntervalWeek
+	// IntervalMonth is a record for each month.
+	IntervalMonth
+	// IntervalQuarter is a record for each quarter of the year.
+	IntervalQuarter
+	// IntervalYear is a record for each year.
+	IntervalYear
+)
+
+var intervalNames = map[Interval]string{
+	IntervalDay:     "day",
+	IntervalWeek:    "week",
+	IntervalMonth:   "month",
+	IntervalQuarter: "quarter",
+	IntervalYear:    "year",
+}
+
+func (i Interval) String() string {
+	return intervalNames[i]
+}
+
+// ParseInterval gets an interval by name: "day", "week", "month", "quarter" or "year".
+func ParseInterval(s string) (Interval, error) {
+	for i, name := range intervalNames {
+		if s == name {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid interval: %s", s)
+}
+
+// start gets the start of the interval a time is in.
+func (i Interval) start(t time.Time) time.Time {
+	y, m, d := t.Date()
+	switch i {
+	case IntervalWeek:
+		return time.Date(y, m, d-int(t.Weekday()), 0, 0, 0, 0, t.Location())
+	case IntervalMonth:
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case IntervalQuarter:
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, t.Location())
+	case IntervalYear:
+		return time.Date(y, time.January, 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+}
+
+// next gets the start of the interval after the one starting at a time.
+func (i Interval) next(start time.Time) time.Time {
+	switch i {
+	case IntervalWeek:
+		return start.AddDate(0, 0, 7)
+	case IntervalMonth:
+		return start.AddDate(0, 1, 0)
+	case IntervalQuarter:
+		return start.AddDate(0, 3, 0)
+	case IntervalYear:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Sampling is how an account's balances during an interval are turned into one balance.
+type Sampling int
+
+const (
+	// SampleEnd is the balance at the end of the interval.
+	SampleEnd Sampling = iota
+	// SampleMin is the lowest balance during the interval.
+	SampleMin
+	// SampleMax is the highest balance during the interval.
+	SampleMax
+	// SampleAverage is the average balance during the interval, weighted by how long the account had each balance.
+	SampleAverage
+)
+
+var samplingNames = map[Sampling]string{
+	SampleEnd:     "end",
+	SampleMin:     "min",
+	SampleMax:     "max",
+	SampleAverage: "average",
+}
+
+func (s Sampling) String() string {
+	return samplingNames[s]
+}
+
+// ParseSampling gets a sampling by name: "end", "min", "max" or "average".
+func ParseSampling(s string) (Sampling, error) {
+	for sampling, name := range samplingNames {
+		if s == name {
+			return sampling, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid sampling: %s", s)
+}
+
+// ResampleRecords turns projection records into a record for each account in each interval, at the start of the interval.
+// An account's balances during an interval include the balance it had at the end of the interval before.
+// Every interval from the first record to the last has records, with the balances carried forward through intervals without any,
+// and the records in an interval have all of its events.
+// The records should be in time order, like those from Project.
+func ResampleRecords(recs []ProjectionRecord, interval Interval, sampling Sampling) []ProjectionRecord {
+	if len(recs) == 0 {
+		return nil
+	}
+
+	// Accounts are told apart by key, and keep their first record for their name
+	var keys []interface{}
+	first := make(map[interface{}]ProjectionRecord)
+	last := make(map[interface{}]float32)
+
+	var resampled []ProjectionRecord
+	for i, start := 0, interval.start(recs[0].Time); i < len(recs); start = interval.next(start) {
+		end := interval.next(start)
+
+		samples := make(map[interface{}]*balanceSample)
+		for _, k := range keys {
+			samples[k] = &balanceSample{}
+			samples[k].add(start, last[k])
+		}
+		for ; i < len(recs) && recs[i].Time.Before(end); i++ {
+			r := recs[i]
+			s, ok := samples[r.key()]
+			if !ok {
+				s = &balanceSample{}
+				samples[r.key()] = s
+				keys = append(keys, r.key())
+				first[r.key()] = r
+			}
+			s.add(r.Time, r.Balance)
+			s.events = append(s.events, r.Events...)
+			last[r.key()] = r.Balance
+		}
+
+		for _, k := range keys {
+			s := samples[k]
+			resampled = append(resampled, ProjectionRecord{
+				Time:        start,
+				AccountName: first[k].AccountName,
+				Balance:     s.value(sampling, end),
+				Events:      s.events,
+				account:     first[k].account,
+			})
+		}
+	}
+	return resampled
+}
+
+// balanceSample collects an account's balances during an interval.
+// The earlier balances are those the account had for some time before its current balance,
+// and weighted is the sum of each of them times the hours the account had it.
+type balanceSample struct {
+	since    time.Time
+	balance  float32
+	earlier  bool
+	min, max float32
+	weighted float64
+	hours    float64
+	events   []string
+}
+
+func (s *balanceSample) add(t time.Time, balance float32) {
+	if !s.since.IsZero() && t.After(s.since) {
+		hours := t.Sub(s.since).Hours()
+		s.weighted += float64(s.balance) * hours
+		s.hours += hours
+		if !s.earlier || s.balance < s.min {
+			s.min = s.balance
+		}
+		if !s.earlier || s.balance > s.max {
+			s.max = s.balance
+		}
+		s.earlier = true
+	}
+	s.since, s.balance = t, balance
+}
+
+func (s *balanceSample) value(sampling Sampling, end time.Time) float32 {
+	switch sampling {
+	case SampleMin:
+		if s.earlier && s.min < s.balance {
+			return s.min
+		}
+		return s.balance
+	case SampleMax:
+		if s.earlier && s.max > s.balance {
+			return s.max
+		}
+		return s.balance
+	case SampleAverage:
+		hours := end.Sub(s.since).Hours()
+		if s.hours+hours == 0 {
+			return s.balance
+		}
+		return float32((s.weighted + float64(s.balance)*hours) / (s.hours + hours))
+	default:
+		return s.balance
+	}
+}
@@ -0,0 +1,83 @@
+package munn
+
+import (
+	"time"
+)
+
+// DefaultRMDStartAge is the age required minimum distributions start at when the portfolio doesn't give one.
+const DefaultRMDStartAge = 73
+
+// UniformLifetimeTable is the IRS Uniform Lifetime Table, mapping an owner's age to the distribution period for their required minimum distribution.
+var UniformLifetimeTable = map[int]float32{
+	72: 27.4, 73: 26.5, 74: 25.5, 75: 24.6, 76: 23.7, 77: 22.9, 78: 22.0, 79: 21.1,
+	80: 20.2, 81: 19.4, 82: 18.5, 83: 17.7, 84: 16.8, 85: 16.0, 86: 15.2, 87: 14.4, 88: 13.7, 89: 12.9,
+	90: 12.2, 91: 11.5, 92: 10.8, 93: 10.1, 94: 9.5, 95: 8.9, 96: 8.4, 97: 7.8, 98: 7.3, 99: 6.8,
+	100: 6.4, 101: 6.0, 102: 5.6, 103: 5.2, 104: 4.9, 105: 4.6, 106: 4.3, 107: 4.1, 108: 3.9, 109: 3.7,
+	110: 3.5, 111: 3.4, 112: 3.3, 113: 3.1, 114: 3.0, 115: 2.9, 116: 2.8, 117: 2.7, 118: 2.5, 119: 2.3,
+	120: 2.0,
+}
+
+// distributionPeriod gets the distribution period for an age from the portfolio's RMD table.
+// Ages past either end of the table use the period for the closest age in the table.
+func (p *Portfolio) distributionPeriod(age int) float32 {
+	table := p.RMDTable
+	if table == nil {
+		table = UniformLifetimeTable
+	}
+	if period, ok := table[age]; ok {
+		return period
+	}
+
+	var closestAge int
+	var period float32
+	for a, pd := range table {
+		if period == 0 || abs(a-age) < abs(closestAge-age) {
+			closestAge, period = a, pd
+		}
+	}
+	return period
+}
+
+func (p *Portfolio) rmdStartAge() int {
+	if p.RMDStartAge == 0 {
+		return DefaultRMDStartAge
+	}
+	return p.RMDStartAge
+}
+
+// TakeRMD forces the yearly required minimum distribution out of a tax-deferred account.
+// The distribution is taken on the first day of each year the owner reaches the portfolio's RMD start age, and moved into RMDAccount if it is set.
+func (a *Account) TakeRMD(now time.Time) bool {
+	if a.TaxTreatment != TaxDeferred || a.OwnerBirthDate == nil {
+		return false
+	}
+	year := now.Year()
+	age := year - a.OwnerBirthDate.Year()
+	if age < a.Portfolio.rmdStartAge() || a.lastRMDYear == year {
+		return false
+	}
+	a.lastRMDYear = year
+
+	amt := withdraw(now, []*Account{a}, a.RMDAccount, a.Balance/a.Portfolio.distributionPeriod(age))
+	a.Portfolio.record(LedgerEntry{
+		Time:        now,
+		Description: "Required minimum distribution",
+		From:        a,
+		To:          a.RMDAccount,
+		Amount:      amt,
+	})
+	if a.RMDAccount != nil {
+		a.RMDAccount.deposit(now, amt)
+		a.Portfolio.logDebug("%s, Forced required minimum distribution of %.2f from account %s to %s\n", now.Format("2006-01-02"), amt, a.Name, a.RMDAccount.Name)
+	} else {
+		a.Portfolio.logDebug("%s, Forced required minimum distribution of %.2f from account %s\n", now.Format("2006-01-02"), amt, a.Name)
+	}
+	return true
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -1,36 +1,133 @@
 package munn
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
 
-// Stats gets stats for a portfolio.
+// Stats gets stats for the last projection of the portfolio, from the money moved during it.
+// Income is money coming into the portfolio and expenses are money leaving it; transfers between accounts are neither.
+// Averages are over the months after the last manual adjustment, since money moved before it isn't projected.
 func (p *Portfolio) Stats() PortfolioStats {
-	var yearlyExpenses float32
-	var yearlyIncome float32
-	for _, t := range p.Transactions {
-		if t.ToAccount == nil {
-			yearlyExpenses += t.Amount * t.Schedule.YearlyFactor()
-		} else if len(t.FromAccounts) > 0 {
-			yearlyIncome += t.Amount * t.Schedule.YearlyFactor()
+	months := float32(monthsBetween(p.ledgerStart, p.end))
+	if p.end.IsZero() {
+		months = 0
+	}
+
+	flows := make(map[*Account]*AccountFlow)
+	for _, a := range p.Accounts {
+		flows[a] = &AccountFlow{Account: a.Name}
+	}
+
+	var income, expenses float32
+	for _, e := range p.ledger {
+		switch e.Kind() {
+		case Income:
+			income += e.Amount
+			flows[e.To].Income += e.Amount
+		case Expense:
+			expenses += e.Amount
+			flows[e.From].Expenses += e.Amount
+		case Transfer:
+			flows[e.From].TransfersOut += e.Amount
+			flows[e.To].TransfersIn += e.Amount
+		}
+	}
+
+	var s PortfolioStats
+	if months <= 0 {
+		return s
+	}
+	s.AverageMonthlyExpenses = expenses / months
+	s.AverageMonthlyIncome = income / months
+	s.AverageMonthlyGrowth = s.AverageMonthlyIncome - s.AverageMonthlyExpenses
+	if s.AverageMonthlyIncome > 0 {
+		s.SavingsRate = s.AverageMonthlyGrowth / s.AverageMonthlyIncome
+	}
+
+	s.MonthlyBurn = -s.AverageMonthlyGrowth
+	if s.MonthlyBurn < 0 {
+		s.MonthlyBurn = 0
+	}
+	for _, a := range p.Accounts {
+		if a.TaxTreatment == Taxable {
+			s.Cash += a.Balance
 		}
 	}
-	return PortfolioStats{
-		AverageMonthlyExpenses: yearlyExpenses / 12,
-		AverageMonthlyIncome:   yearlyIncome / 12,
-		AverageMonthlyGrowth:   (yearlyIncome - yearlyExpenses) / 12,
+	if s.MonthlyBurn > 0 {
+		s.RunwayMonths = s.Cash / s.MonthlyBurn
+	} else {
+		s.RunwayMonths = float32(math.Inf(1))
+	}
+
+	for _, a := range p.Accounts {
+		f := flows[a]
+		f.Income /= months
+		f.Expenses /= months
+		f.TransfersIn /= months
+		f.TransfersOut /= months
+		f.Net = f.Income + f.TransfersIn - f.Expenses - f.TransfersOut
+		s.Accounts = append(s.Accounts, *f)
 	}
+	return s
 }
 
 // PortfolioStats is a collection of stats about the portfolio.
+// Amounts are monthly averages over the projection.
 type PortfolioStats struct {
-	AverageMonthlyExpenses float32
-	AverageMonthlyIncome   float32
-	AverageMonthlyGrowth   float32
+	AverageMonthlyExpenses float32 `json:"averageMonthlyExpenses"`
+	AverageMonthlyIncome   float32 `json:"averageMonthlyIncome"`
+	AverageMonthlyGrowth   float32 `json:"averageMonthlyGrowth"`
+	// SavingsRate is the share of income which isn't spent.
+	SavingsRate float32 `json:"savingsRate"`
+	// MonthlyBurn is how much more is spent than earned each month, or 0 if the portfolio earns more than it spends.
+	MonthlyBurn float32 `json:"monthlyBurn"`
+	// Cash is the balance of the taxable (non-retirement) accounts at the end of the projection.
+	Cash float32 `json:"cash"`
+	// RunwayMonths is how many months the cash lasts at the monthly burn, or +Inf if there is no burn.
+	RunwayMonths float32       `json:"runwayMonths"`
+	Accounts     []AccountFlow `json:"accounts"`
+}
+
+// AccountFlow is the average money moved into and out of an account each month.
+type AccountFlow struct {
+	Account      string  `json:"account"`
+	Income       float32 `json:"income"`
+	Expenses     float32 `json:"expenses"`
+	TransfersIn  float32 `json:"transfersIn"`
+	TransfersOut float32 `json:"transfersOut"`
+	Net          float32 `json:"net"`
+}
+
+// MarshalJSON encodes the stats with an indefinite runway as null, since JSON has no infinity.
+func (s PortfolioStats) MarshalJSON() ([]byte, error) {
+	type stats PortfolioStats
+	v := struct {
+		stats
+		RunwayMonths *float32 `json:"runwayMonths"`
+	}{stats: stats(s)}
+	if !math.IsInf(float64(s.RunwayMonths), 1) {
+		v.RunwayMonths = &s.RunwayMonths
+	}
+	return json.Marshal(v)
 }
 
 func (s PortfolioStats) String() string {
 	var o string
 	o += fmt.Sprintf("Average monthly expenses:  $%.2f\n", s.AverageMonthlyExpenses)
 	o += fmt.Sprintf("Average monthly income:    $%.2f\n", s.AverageMonthlyIncome)
-	o += fmt.Sprintf("Average monthly growth:    $%.2f", s.AverageMonthlyGrowth)
+	o += fmt.Sprintf("Average monthly growth:    $%.2f\n", s.AverageMonthlyGrowth)
+	o += fmt.Sprintf("Savings rate:              %.2f%%\n", s.SavingsRate*100)
+	o += fmt.Sprintf("Average monthly burn:      $%.2f\n", s.MonthlyBurn)
+	if math.IsInf(float64(s.RunwayMonths), 1) {
+		o += "Runway:                    indefinite"
+	} else {
+		o += fmt.Sprintf("Runway:                    %.1f months", s.RunwayMonths)
+	}
+	for _, f := range s.Accounts {
+		o += fmt.Sprintf("\nNet monthly flow for %s:  $%.2f (income $%.2f, expenses $%.2f, transfers in $%.2f, transfers out $%.2f)",
+			f.Account, f.Net, f.Income, f.Expenses, f.TransfersIn, f.TransfersOut)
+	}
 	return o
 }
@@ -0,0 +1,245 @@
+package munn
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// TaxCategory is the category of income tax payments.
+const TaxCategory = "Taxes"
+
+// TaxTreatment is how money in an account is taxed.
+type TaxTreatment int
+
+const (
+	// Taxable accounts hold money which has already been taxed.
+	Taxable TaxTreatment = iota
+	// TaxDeferred accounts are taxed as income when money is withdrawn.
+	TaxDeferred
+	// TaxFree accounts are never taxed.
+	TaxFree
+)
+
+var taxTreatmentNames = map[TaxTreatment]string{
+	Taxable:     "taxable",
+	TaxDeferred: "taxDeferred",
+	TaxFree:     "taxFree",
+}
+
+func (t TaxTreatment) String() string {
+	return taxTreatmentNames[t]
+}
+
+// ParseTaxTreatment parses a tax treatment from its name.
+func ParseTaxTreatment(s string) (TaxTreatment, error) {
+	for t, name := range taxTreatmentNames {
+		if strings.EqualFold(s, name) {
+			return t, nil
+		}
+	}
+	return Taxable, fmt.Errorf("invalid tax treatment: %s", s)
+}
+
+// TaxBracket is a marginal tax rate applied to taxable income over an amount.
+type TaxBracket struct {
+	Over float32
+	Rate float32
+}
+
+// TaxTable is the standard deduction and progressive tax brackets for a year.
+// Brackets must be sorted by Over.
+type TaxTable struct {
+	Year              int
+	StandardDeduction float32
+	Brackets          []TaxBracket
+}
+
+// Tax gets the tax owed and the marginal rate for an amount of taxable income.
+func (tt TaxTable) Tax(taxableIncome float32) (tax float32, marginalRate float32) {
+	for i, b := range tt.Brackets {
+		if taxableIncome <= b.Over {
+			break
+		}
+		top := taxableIncome
+		if i+1 < len(tt.Brackets) && tt.Brackets[i+1].Over < top {
+			top = tt.Brackets[i+1].Over
+		}
+		tax += (top - b.Over) * b.Rate
+		marginalRate = b.Rate
+	}
+	if marginalRate == 0 && len(tt.Brackets) > 0 {
+		marginalRate = tt.Brackets[0].Rate
+	}
+	return tax, marginalRate
+}
+
+// Taxes calculates yearly income tax for a portfolio.
+// The table for a year is the latest table at or before that year, and a table used for a later year is indexed by Inflation.
+// Income tax for a year is paid out of Account on the first day of the next year.
+// If Account is nil, taxes are only reported.
+type Taxes struct {
+	Tables    []TaxTable
+	Inflation float32
+	Account   *Account
+	records   []TaxRecord
+	current   *TaxRecord
+}
+
+// TaxRecord is the income tax for a year.
+type TaxRecord struct {
+	Year          int
+	Income        float32
+	Deductions    float32
+	TaxableIncome float32
+	Tax           float32
+	EffectiveRate float32
+	MarginalRate  float32
+	// Withheld is tax already paid during the year (such as vests sold to cover taxes), so only the rest of the tax is paid.
+	Withheld float32
+}
+
+// Table gets the tax table for a year.
+// Years before the first table use the first table.
+func (t *Taxes) Table(year int) TaxTable {
+	if len(t.Tables) == 0 {
+		return TaxTable{Year: year}
+	}
+	table := t.Tables[0]
+	for _, tt := range t.Tables[1:] {
+		if tt.Year > year {
+			break
+		}
+		table = tt
+	}
+	if table.Year >= year || t.Inflation == 0 {
+		return table
+	}
+
+	factor := float32(math.Pow(float64(1+t.Inflation), float64(year-table.Year)))
+	indexed := TaxTable{
+		Year:              year,
+		StandardDeduction: table.StandardDeduction * factor,
+	}
+	for _, b := range table.Brackets {
+		indexed.Brackets = append(indexed.Brackets, TaxBracket{
+			Over: b.Over * factor,
+			Rate: b.Rate,
+		})
+	}
+	return indexed
+}
+
+// Records gets the income tax for each year of the last projection.
+func (t *Taxes) Records() []TaxRecord {
+	return t.records
+}
+
+func (t *Taxes) reset() {
+	t.records = nil
+	t.current = nil
+}
+
+// settleTaxes finishes the current tax year once a new year has started, and pays the tax which wasn't withheld
+// (or gets back what was withheld beyond the tax).
+func (p *Portfolio) settleTaxes(now time.Time) bool {
+	t := p.Taxes
+	if t == nil {
+		return false
+	}
+	if t.current == nil {
+		t.current = &TaxRecord{Year: now.Year()}
+		return false
+	}
+	if t.current.Year == now.Year() {
+		return false
+	}
+
+	rec := t.finishYear()
+	t.current = &TaxRecord{Year: now.Year()}
+
+	owed := rec.Tax - rec.Withheld
+	if t.Account == nil || owed == 0 {
+		return false
+	}
+	if owed < 0 {
+		t.Account.deposit(now, -owed)
+		p.record(LedgerEntry{
+			Time:        now,
+			Description: fmt.Sprintf("Income tax refund for %d", rec.Year),
+			Category:    TaxCategory,
+			To:          t.Account,
+			Amount:      -owed,
+		})
+		p.logDebug("%s, Applied income tax refund of %.2f for %d\n", now.Format("2006-01-02"), -owed, rec.Year)
+		return true
+	}
+	paid := withdraw(now, []*Account{t.Account}, nil, owed)
+	p.record(LedgerEntry{
+		Time:        now,
+		Description: fmt.Sprintf("Income tax for %d", rec.Year),
+		Category:    TaxCategory,
+		From:        t.Account,
+		Amount:      paid,
+	})
+	p.logDebug("%s, Applied income tax of %.2f for %d\n", now.Format("2006-01-02"), paid, rec.Year)
+	return true
+}
+
+// finishYear calculates the tax for the current year and records it.
+func (t *Taxes) finishYear() TaxRecord {
+	rec := *t.current
+	table := t.Table(rec.Year)
+	rec.TaxableIncome = rec.Income - rec.Deductions - table.StandardDeduction
+	if rec.TaxableIncome < 0 {
+		rec.TaxableIncome = 0
+	}
+	rec.Tax, rec.MarginalRate = table.Tax(rec.TaxableIncome)
+	if rec.Income > 0 {
+		rec.EffectiveRate = rec.Tax / rec.Income
+	}
+	t.records = append(t.records, rec)
+	return rec
+}
+
+// finishTaxes records the tax for the final, partial year of a projection without paying it.
+func (p *Portfolio) finishTaxes() {
+	if p.Taxes == nil || p.Taxes.current == nil {
+		return
+	}
+	p.Taxes.finishYear()
+	p.Taxes.current = nil
+}
+
+func (p *Portfolio) addTaxableIncome(amt float32) {
+	if p.Taxes == nil || p.Taxes.current == nil {
+		return
+	}
+	p.Taxes.current.Income += amt
+}
+
+func (p *Portfolio) addTaxWithheld(amt float32) {
+	if p.Taxes == nil || p.Taxes.current == nil {
+		return
+	}
+	p.Taxes.current.Withheld += amt
+}
+
+func (p *Portfolio) addTaxDeduction(amt float32) {
+	if p.Taxes == nil || p.Taxes.current == nil {
+		return
+	}
+	p.Taxes.current.Deductions += amt
+}
+
+// addWithdrawal counts money moved out of a tax-deferred account as taxable income, unless it is moved into another tax-deferred account.
+func (p *Portfolio) addWithdrawal(from, to *Account, amt float32) {
+	if from.TaxTreatment != TaxDeferred {
+		return
+	}
+	if to != nil && to.TaxTreatment == TaxDeferred {
+		return
+	}
+	p.addTaxableIncome(amt)
+}
@@ -0,0 +1,90 @@
+package munn
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// VestingGrant is a grant of shares (such as RSUs or stock options) which vest over time.
+// No shares vest until CliffMonths after Start, and then shares vest in tranches every IntervalMonths until VestingMonths after Start.
+// Each vest deposits the vested shares times the share price, which grows yearly by PriceGrowth, minus the Withholding rate sold to cover taxes.
+// If the grant is Taxable, the whole value of each vest is income and the withholding is tax already paid for the year.
+type VestingGrant struct {
+	Description    string
+	Shares         float32
+	Price          float32
+	PriceGrowth    float32
+	Start          time.Time
+	CliffMonths    int
+	VestingMonths  int
+	IntervalMonths int
+	Withholding    float32
+	Taxable        bool
+}
+
+// Vest is a single tranche of a vesting grant.
+// Amount is what is deposited, after Withheld is sold to cover taxes.
+type Vest struct {
+	Time     time.Time
+	Shares   float32
+	Price    float32
+	Amount   float32
+	Withheld float32
+}
+
+// Vests gets every tranche of the grant.
+func (g *VestingGrant) Vests() []Vest {
+	if g.VestingMonths <= 0 || g.IntervalMonths <= 0 {
+		return nil
+	}
+
+	month := g.CliffMonths
+	if month <= 0 {
+		month = g.IntervalMonths
+	}
+
+	var vests []Vest
+	var vested float32
+	for {
+		if month > g.VestingMonths {
+			month = g.VestingMonths
+		}
+		total := g.Shares * float32(month) / float32(g.VestingMonths)
+		t := g.Start.AddDate(0, month, 0)
+		years := t.Sub(g.Start).Hours() / 24 / 365.25
+		price := g.Price * float32(math.Pow(float64(1+g.PriceGrowth), years))
+		shares := total - vested
+		vested = total
+		vests = append(vests, Vest{
+			Time:     t,
+			Shares:   shares,
+			Price:    price,
+			Amount:   shares * price * (1 - g.Withholding),
+			Withheld: shares * price * g.Withholding,
+		})
+
+		if month == g.VestingMonths {
+			break
+		}
+		month += g.IntervalMonths
+	}
+	return vests
+}
+
+// NewVestingGrant adds a vesting grant to the portfolio, as a one-time income transaction into the account for each vest.
+func (p *Portfolio) NewVestingGrant(to *Account, g *VestingGrant) []*Transaction {
+	p.VestingGrants = append(p.VestingGrants, g)
+
+	vests := g.Vests()
+	var trans []*Transaction
+	for i, v := range vests {
+		desc := fmt.Sprintf("%s vest %d/%d (%.2f shares @ $%.2f)", g.Description, i+1, len(vests), v.Shares, v.Price)
+		t := p.NewTransaction(nil, to, desc, Once(v.Time), nil, nil, v.Amount)
+		t.Taxable = g.Taxable
+		t.grant = g
+		t.withheld = v.Withheld
+		trans = append(trans, t)
+	}
+	return trans
+}
@@ -11,6 +11,7 @@ func init() {
 	RegisterScheduleParser("Weekly", &weeklySchedule{})
 	RegisterScheduleParser("Biweekly", &biweeklySchedule{})
 	RegisterScheduleParser("Monthly", &monthlySchedule{})
+	RegisterScheduleParser("Yearly", &yearlySchedule{})
 	RegisterScheduleParser("Once", &onceSchedule{})
 }
 
@@ -61,6 +62,14 @@ func (s *weeklySchedule) ParseSchedule(args []string) (Schedule, error) {
 	return Weekly(day), nil
 }
 
+func (s *weeklySchedule) FormatSchedule(sched Schedule) ([]string, bool) {
+	w, ok := sched.(*weeklySchedule)
+	if !ok {
+		return nil, false
+	}
+	return []string{w.weekday.String()}, true
+}
+
 // Biweekly schedule will run biweekly on the given weekday.
 func Biweekly(day time.Weekday) Schedule {
 	return &biweeklySchedule{
@@ -101,6 +110,14 @@ func (s *biweeklySchedule) ParseSchedule(args []string) (Schedule, error) {
 	return Biweekly(day), nil
 }
 
+func (s *biweeklySchedule) FormatSchedule(sched Schedule) ([]string, bool) {
+	b, ok := sched.(*biweeklySchedule)
+	if !ok {
+		return nil, false
+	}
+	return []string{b.weekday.String()}, true
+}
+
 type monthlySchedule struct {
 	day         int
 	lastApplied time.Time
@@ -138,6 +155,69 @@ func (s *monthlySchedule) ParseSchedule(args []string) (Schedule, error) {
 	return Monthly(day), nil
 }
 
+func (s *monthlySchedule) FormatSchedule(sched Schedule) ([]string, bool) {
+	m, ok := sched.(*monthlySchedule)
+	if !ok {
+		return nil, false
+	}
+	return []string{strconv.Itoa(m.day)}, true
+}
+
+type yearlySchedule struct {
+	month       time.Month
+	day         int
+	lastApplied time.Time
+}
+
+// Yearly schedule will run yearly on the given month and day of the month.
+func Yearly(month time.Month, day int) Schedule {
+	return &yearlySchedule{
+		month: month,
+		day:   day,
+	}
+}
+
+func (s *yearlySchedule) ShouldApply(t time.Time) bool {
+	year := s.lastApplied.AddDate(1, 0, 0).Year()
+	if t.Before(time.Date(year, s.month, s.day, 0, 0, 0, 0, time.Local)) {
+		return false
+	}
+	s.lastApplied = t
+	return true
+}
+
+func (s *yearlySchedule) YearlyFactor() float32 {
+	return 1
+}
+
+func (s *yearlySchedule) ParseSchedule(args []string) (Schedule, error) {
+	month := time.January
+	day := 1
+	if len(args) > 0 {
+		var ok bool
+		month, ok = monthsOfYear[strings.ToLower(args[0])]
+		if !ok {
+			return nil, fmt.Errorf("invalid month: %s", args[0])
+		}
+	}
+	if len(args) > 1 {
+		var err error
+		day, err = strconv.Atoi(args[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return Yearly(month, day), nil
+}
+
+func (s *yearlySchedule) FormatSchedule(sched Schedule) ([]string, bool) {
+	y, ok := sched.(*yearlySchedule)
+	if !ok {
+		return nil, false
+	}
+	return []string{y.month.String(), strconv.Itoa(y.day)}, true
+}
+
 type onceSchedule struct {
 	time    time.Time
 	applied bool
@@ -174,3 +254,11 @@ func (s *onceSchedule) ParseSchedule(args []string) (Schedule, error) {
 	}
 	return Once(t), nil
 }
+
+func (s *onceSchedule) FormatSchedule(sched Schedule) ([]string, bool) {
+	o, ok := sched.(*onceSchedule)
+	if !ok {
+		return nil, false
+	}
+	return []string{o.time.Format("2006-01-02")}, true
+}
@@ -0,0 +1,132 @@
+package munn
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+var (
+	accountRefType  = reflect.TypeOf(accountRef(""))
+	accountRefsType = reflect.TypeOf(accountRefs{})
+)
+
+// requiredFields are the fields which are errors to leave out.
+var requiredFields = map[reflect.Type][]string{
+	reflect.TypeOf(manualAdjustmentSpec{}): {"account", "time", "balance"},
+	reflect.TypeOf(transactionSpec{}):      {"schedule"},
+	reflect.TypeOf(pensionSpec{}):          {"toAccount", "benefit", "birthDate", "claimDate"},
+	reflect.TypeOf(grantSpec{}):            {"toAccount", "shares", "price", "start"},
+	reflect.TypeOf(rebalanceSpec{}):        {"schedule", "targets"},
+	reflect.TypeOf(rebalanceTargetSpec{}):  {"account", "percent"},
+}
+
+// oneOfFields are the fields which exactly one of is required.
+var oneOfFields = map[reflect.Type][]string{
+	reflect.TypeOf(transactionSpec{}): {"amount", "salaryRate"},
+}
+
+// JSONSchema gets a JSON Schema for portfolio files, so editors can autocomplete and check them.
+func JSONSchema() ([]byte, error) {
+	s := schemaOf(reflect.TypeOf(portfolioSpec{}))
+	s["$schema"] = "http://json-schema.org/draft-07/schema#"
+	s["title"] = "munn portfolio"
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// schemaOf gets the schema for a spec type.
+// Numbers and dates can also be expressions, which are strings starting with =.
+func schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	expression := map[string]interface{}{"type": "string", "pattern": "^="}
+
+	switch t {
+	case laxTimeType:
+		return map[string]interface{}{"anyOf": []interface{}{
+			map[string]interface{}{"type": "string", "format": "date"},
+			map[string]interface{}{"type": "string", "format": "date-time"},
+			expression,
+		}}
+	case jsonScheduleType:
+		return map[string]interface{}{
+			"type":     "string",
+			"pattern":  jsonScheduleRegex.String(),
+			"examples": []string{"Weekly(Thursday)", "Biweekly(Friday)", "Monthly(15)", "Yearly(January 1)", "Once(2020-01-01)"},
+		}
+	case accountRefType:
+		return map[string]interface{}{"type": []string{"integer", "string"}}
+	case accountRefsType:
+		ref := schemaOf(accountRefType)
+		return map[string]interface{}{"anyOf": []interface{}{
+			ref,
+			map[string]interface{}{"type": "array", "items": ref},
+		}}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name, prop := yamlName(f), schemaOf(f.Type)
+			switch name {
+			case "taxTreatment":
+				prop["examples"] = enumNames(taxTreatmentNames)
+			case "costBasis":
+				prop["examples"] = enumNames(costBasisMethodNames)
+			}
+			props[name] = prop
+		}
+		s := map[string]interface{}{
+			"type":                 "object",
+			"properties":           props,
+			"additionalProperties": false,
+		}
+		if required, ok := requiredFields[t]; ok {
+			s["required"] = required
+		}
+		if fields, ok := oneOfFields[t]; ok {
+			var oneOf []interface{}
+			for _, f := range fields {
+				oneOf = append(oneOf, map[string]interface{}{"required": []string{f}})
+			}
+			s["oneOf"] = oneOf
+		}
+		return s
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		s := map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+		if t.Key().Kind() == reflect.Int {
+			s["propertyNames"] = map[string]interface{}{"pattern": "^[0-9]+$"}
+		}
+		return s
+	case reflect.Int:
+		return map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "integer"}, expression}}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "number"}, expression}}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	default:
+		// Variables are numbers, or strings with dates, durations or expressions
+		return map[string]interface{}{"type": []string{"number", "string"}}
+	}
+}
+
+// enumNames gets the sorted names from a map of enum values to their names.
+func enumNames(m interface{}) []string {
+	v := reflect.ValueOf(m)
+	var s []string
+	for _, k := range v.MapKeys() {
+		s = append(s, v.MapIndex(k).String())
+	}
+	sort.Strings(s)
+	return s
+}
@@ -0,0 +1,104 @@
+package munn
+
+import (
+	"path/filepath"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Position is a place in a portfolio file, with lines and columns starting at 1.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// source records where the parts of a portfolio were read from, so editors can find them.
+type source struct {
+	accounts     map[*Account]Position
+	refs         []sourceRef
+	transactions []sourceLines
+}
+
+// sourceRef is a mention of an account, which is either its definition or a reference to it.
+// end is the column after the mention.
+type sourceRef struct {
+	pos Position
+	end int
+	acc *Account
+}
+
+// sourceLines is the lines a transaction was read from.
+type sourceLines struct {
+	file       string
+	start, end int
+	trans      *Transaction
+}
+
+// AccountPosition gets where an account was defined, if the portfolio was read from a file.
+func (p *Portfolio) AccountPosition(a *Account) (Position, bool) {
+	pos, ok := p.source.accounts[a]
+	return pos, ok
+}
+
+// AccountAt finds the account defined or referred to at a position.
+func (p *Portfolio) AccountAt(pos Position) (*Account, bool) {
+	for _, r := range p.source.refs {
+		if sameFile(r.pos.File, pos.File) && r.pos.Line == pos.Line && r.pos.Column <= pos.Column && pos.Column <= r.end {
+			return r.acc, true
+		}
+	}
+	return nil, false
+}
+
+// TransactionAt finds the transaction defined on the line of a position.
+func (p *Portfolio) TransactionAt(pos Position) (*Transaction, bool) {
+	for _, l := range p.source.transactions {
+		if sameFile(l.file, pos.File) && l.start <= pos.Line && pos.Line <= l.end {
+			return l.trans, true
+		}
+	}
+	return nil, false
+}
+
+func sameFile(a, b string) bool {
+	return filepath.Clean(a) == filepath.Clean(b)
+}
+
+func (ps *parser) pos(n *yaml.Node) Position {
+	return Position{File: ps.nodeFiles[n], Line: n.Line, Column: n.Column}
+}
+
+// defineAccount records where an account is defined, at its name or the whole account if it has none.
+func (ps *parser) defineAccount(p *Portfolio, i int, acc *Account) {
+	if p.source.accounts == nil {
+		p.source.accounts = make(map[*Account]Position)
+	}
+	p.source.accounts[acc] = ps.pos(ps.at("accounts", i, "name"))
+	ps.refAccount(p, ps.at("accounts", i, "name"), acc)
+	ps.refAccount(p, ps.at("accounts", i, "id"), acc)
+}
+
+// refAccount records a mention of an account.
+func (ps *parser) refAccount(p *Portfolio, n *yaml.Node, acc *Account) {
+	if n.Kind != yaml.ScalarNode || acc == nil {
+		return
+	}
+	end := n.Column + utf8.RuneCountInString(n.Value)
+	if n.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		end += 2
+	}
+	p.source.refs = append(p.source.refs, sourceRef{pos: ps.pos(n), end: end, acc: acc})
+}
+
+// defineTransaction records the lines a transaction was read from, which are the lines of all its fields.
+func (ps *parser) defineTransaction(p *Portfolio, n *yaml.Node, t *Transaction) {
+	l := sourceLines{file: ps.nodeFiles[n], start: n.Line, end: n.Line, trans: t}
+	walkNodes(n, func(c *yaml.Node) {
+		if c.Line > l.end {
+			l.end = c.Line
+		}
+	})
+	p.source.transactions = append(p.source.transactions, l)
+}
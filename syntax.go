@@ -0,0 +1,258 @@
+package munn
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// syntax is a language portfolio files can be written in.
+// Every syntax is read into YAML nodes, so files are checked and decoded the same way whatever they are written in.
+type syntax int
+
+const (
+	syntaxYAML syntax = iota
+	syntaxJSON
+	syntaxTOML
+)
+
+// syntaxOf detects the syntax of a file from its extension, which is YAML unless it is .json or .toml.
+func syntaxOf(name string) syntax {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return syntaxJSON
+	case ".toml":
+		return syntaxTOML
+	default:
+		return syntaxYAML
+	}
+}
+
+// document reads a file into YAML nodes.
+// JSON is read as YAML, which it is a subset of.
+func (s syntax) document(data []byte) (*yaml.Node, error) {
+	if s == syntaxTOML {
+		return tomlDocument(data)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return doc.Content[0], nil
+}
+
+// tomlDocument reads a TOML document into YAML nodes, with the positions of the keys and values in the TOML.
+// Errors have positions in the same format as yaml.v3.
+func tomlDocument(data []byte) (*yaml.Node, error) {
+	b := &tomlBuilder{data: data}
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: 1, Column: 1}
+	table := root
+	p := unstable.Parser{}
+	p.Reset(data)
+	for p.NextExpression() {
+		e := p.Expression()
+		switch e.Kind {
+		case unstable.Table:
+			table = b.table(root, b.keys(e.Key()), false)
+		case unstable.ArrayTable:
+			table = b.table(root, b.keys(e.Key()), true)
+		case unstable.KeyValue:
+			b.keyValue(table, e)
+		}
+	}
+	if err := p.Error(); err != nil {
+		var pe *unstable.ParserError
+		if errors.As(err, &pe) {
+			if line, col, ok := b.offset(pe.Highlight); ok {
+				return nil, fmt.Errorf("line %d:%d: %s", line, col, pe.Message)
+			}
+		}
+		return nil, err
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	// Decoding finds the errors left, such as a table defined twice
+	var v map[string]interface{}
+	if err := toml.Unmarshal(data, &v); err != nil {
+		var de *toml.DecodeError
+		if errors.As(err, &de) {
+			row, col := de.Position()
+			return nil, fmt.Errorf("line %d:%d: %s", row, col, strings.TrimPrefix(de.Error(), "toml: "))
+		}
+		return nil, errors.New(strings.TrimPrefix(err.Error(), "toml: "))
+	}
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+	return root, nil
+}
+
+// tomlBuilder builds YAML nodes from TOML, stopping at the first key defined twice.
+type tomlBuilder struct {
+	data []byte
+	err  error
+}
+
+// pos finds where a node is in the TOML.
+// Keys and strings have their range, and other values are slices of the TOML.
+func (b *tomlBuilder) pos(n *unstable.Node) (line, col int, ok bool) {
+	if n.Raw.Length > 0 {
+		return b.offset(b.data[n.Raw.Offset:])
+	}
+	return b.offset(n.Data)
+}
+
+// offset finds where a slice of the TOML starts.
+func (b *tomlBuilder) offset(s []byte) (line, col int, ok bool) {
+	o := cap(b.data) - cap(s)
+	if len(s) == 0 || o < 0 || o+len(s) > len(b.data) || !bytes.Equal(b.data[o:o+len(s)], s) {
+		return 0, 0, false
+	}
+	lead := b.data[:o]
+	// Columns count characters, like yaml.v3
+	return bytes.Count(lead, []byte{'\n'}) + 1, utf8.RuneCount(lead[bytes.LastIndexByte(lead, '\n')+1:]) + 1, true
+}
+
+func (b *tomlBuilder) keys(it unstable.Iterator) []*unstable.Node {
+	var keys []*unstable.Node
+	for it.Next() {
+		keys = append(keys, it.Node())
+	}
+	return keys
+}
+
+func (b *tomlBuilder) key(k *unstable.Node) *yaml.Node {
+	n := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(k.Data)}
+	// Keys of integer maps (like the RMD table) are numbers
+	if _, err := strconv.Atoi(n.Value); err == nil {
+		n.Tag = "!!int"
+	}
+	n.Line, n.Column, _ = b.pos(k)
+	return n
+}
+
+// child gets the table for a key in a table, adding it if needed.
+// The key of an array of tables gets its last table.
+func (b *tomlBuilder) child(m *yaml.Node, k *unstable.Node) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == string(k.Data) {
+			v := m.Content[i+1]
+			if v.Kind == yaml.SequenceNode && len(v.Content) > 0 {
+				return v.Content[len(v.Content)-1]
+			}
+			return v
+		}
+	}
+	key := b.key(k)
+	v := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: key.Line, Column: key.Column}
+	m.Content = append(m.Content, key, v)
+	return v
+}
+
+// table gets the table for a [table] or [[array of tables]] header.
+func (b *tomlBuilder) table(root *yaml.Node, keys []*unstable.Node, array bool) *yaml.Node {
+	m := root
+	for _, k := range keys[:len(keys)-1] {
+		m = b.child(m, k)
+	}
+	last := keys[len(keys)-1]
+	if !array {
+		return b.child(m, last)
+	}
+
+	var seq *yaml.Node
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == string(last.Data) {
+			seq = m.Content[i+1]
+		}
+	}
+	key := b.key(last)
+	if seq == nil {
+		seq = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Line: key.Line, Column: key.Column}
+		m.Content = append(m.Content, key, seq)
+	}
+	t := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: key.Line, Column: key.Column}
+	seq.Content = append(seq.Content, t)
+	return t
+}
+
+func (b *tomlBuilder) keyValue(m *yaml.Node, e *unstable.Node) {
+	keys := b.keys(e.Key())
+	for _, k := range keys[:len(keys)-1] {
+		m = b.child(m, k)
+	}
+	key := b.key(keys[len(keys)-1])
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key.Value && b.err == nil {
+			b.err = fmt.Errorf("line %d:%d: key %s is already defined", key.Line, key.Column, key.Value)
+		}
+	}
+	m.Content = append(m.Content, key, b.value(e.Value(), key.Line, key.Column))
+}
+
+// value converts a TOML value, which is at the given position if it doesn't have its own.
+func (b *tomlBuilder) value(v *unstable.Node, line, col int) *yaml.Node {
+	if l, c, ok := b.pos(v); ok {
+		line, col = l, c
+	}
+	n := &yaml.Node{Kind: yaml.ScalarNode, Line: line, Column: col}
+	s := string(v.Data)
+	switch v.Kind {
+	case unstable.String:
+		// Strings start at their quote, like quoted strings in YAML
+		n.Tag, n.Value, n.Style = "!!str", s, yaml.DoubleQuotedStyle
+		if v.Raw.Length > 0 && b.data[v.Raw.Offset] == '\'' {
+			n.Style = yaml.SingleQuotedStyle
+		}
+	case unstable.Bool:
+		n.Tag, n.Value = "!!bool", s
+	case unstable.Integer:
+		i, _ := strconv.ParseInt(strings.ReplaceAll(s, "_", ""), 0, 64)
+		n.Tag, n.Value = "!!int", strconv.FormatInt(i, 10)
+	case unstable.Float:
+		f, _ := strconv.ParseFloat(strings.ReplaceAll(s, "_", ""), 64)
+		n.Tag, n.Value = "!!float", strconv.FormatFloat(f, 'g', -1, 64)
+		switch {
+		case math.IsInf(f, 1):
+			n.Value = ".inf"
+		case math.IsInf(f, -1):
+			n.Value = "-.inf"
+		case math.IsNaN(f):
+			n.Value = ".nan"
+		}
+	case unstable.DateTime:
+		// TOML allows a space instead of a T between the date and time
+		n.Tag, n.Value = "!!str", strings.Replace(s, " ", "T", 1)
+	case unstable.LocalDate, unstable.LocalDateTime, unstable.LocalTime:
+		n.Tag, n.Value = "!!str", s
+	case unstable.Array:
+		n.Kind, n.Tag = yaml.SequenceNode, "!!seq"
+		for it := v.Children(); it.Next(); {
+			if c := it.Node(); c.Kind != unstable.Comment {
+				n.Content = append(n.Content, b.value(c, line, col))
+			}
+		}
+	case unstable.InlineTable:
+		n.Kind, n.Tag = yaml.MappingNode, "!!map"
+		for it := v.Children(); it.Next(); {
+			if c := it.Node(); c.Kind == unstable.KeyValue {
+				b.keyValue(n, c)
+			}
+		}
+	}
+	return n
+}